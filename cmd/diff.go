@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/diff"
+	"github.com/pete911/awf/internal/store"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultDiffSince = 7 * 24 * time.Hour
+
+var (
+	diffFlags flag.Diff
+	diffCmd   = &cobra.Command{
+		Use:   "diff [<snapshot-from> <snapshot-to>]",
+		Short: "show added, removed and changed vpcs, subnets and network interfaces between imports",
+		Long: `compare imported snapshots, by default the snapshot current 7 days ago (see --since) is compared with the
+latest import. Snapshots can be set explicitly by time, e.g. awf diff 2026-10-01 2026-10-08T12:00Z`,
+		Args: cobra.MatchAll(cobra.RangeArgs(0, 2), func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return fmt.Errorf("diff requires two snapshots, or none to use --since")
+			}
+			if len(args) == 2 && cmd.Flags().Changed("since") {
+				return fmt.Errorf("--since cannot be used with snapshot arguments")
+			}
+			return nil
+		}),
+		Run: runDiff,
+	}
+)
+
+func init() {
+	Root.AddCommand(diffCmd)
+	flag.InitDiffFlags(diffCmd, &diffFlags)
+}
+
+func runDiff(_ *cobra.Command, args []string) {
+	if diffFlags.Output != "table" && diffFlags.Output != "json" {
+		fmt.Printf("invalid output %s, only table or json is supported\n", diffFlags.Output)
+		os.Exit(1)
+	}
+	from, to, err := diffTimes(args)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	dataStore, unlock := LoadStore()
+	defer unlock()
+	changes, err := diffSnapshots(dataStore.At(from), dataStore.At(to))
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	if diffFlags.Output == "json" {
+		printDiffJson(from, to, changes)
+	} else {
+		printDiff(from, to, changes)
+	}
+	PrintWarnings(dataStore)
+}
+
+// diffTimes returns times of compared snapshots, zero time is the latest import
+func diffTimes(args []string) (time.Time, time.Time, error) {
+	if len(args) == 2 {
+		from, err := flag.ParseTime(args[0])
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to, err := flag.ParseTime(args[1])
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return from, to, nil
+	}
+
+	since := time.Duration(diffFlags.Since)
+	if since == 0 {
+		since = defaultDiffSince
+	}
+	return time.Now().UTC().Add(-since), time.Time{}, nil
+}
+
+func diffSnapshots(from, to store.Store) (diff.Changes, error) {
+	fromVpcs, err := from.DescribeVpcs()
+	if err != nil {
+		return nil, err
+	}
+	toVpcs, err := to.DescribeVpcs()
+	if err != nil {
+		return nil, err
+	}
+	fromSubnets, err := from.DescribeSubnets()
+	if err != nil {
+		return nil, err
+	}
+	toSubnets, err := to.DescribeSubnets()
+	if err != nil {
+		return nil, err
+	}
+	fromNis, err := from.DescribeNetworkInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	toNis, err := to.DescribeNetworkInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	return diff.Diff(fromVpcs, toVpcs, fromSubnets, toSubnets, fromNis, toNis), nil
+}
+
+func printDiff(from, to time.Time, changes diff.Changes) {
+	fmt.Printf("comparing snapshots at %s and %s\n\n", formatSnapshotTime(from), formatSnapshotTime(to))
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return
+	}
+
+	counts := make(map[string]int)
+	table := NewTable()
+	table.AddRow("ACCOUNT ID", "REGION", "RESOURCE", "ID", "NAME", "CHANGE", "FIELDS")
+	for _, v := range changes {
+		counts[v.Type]++
+		var fields []string
+		for _, f := range v.Fields {
+			fields = append(fields, fmt.Sprintf("%s: %s -> %s", f.Name, f.From, f.To))
+		}
+		table.AddRow(v.AccountId, v.Region, v.Resource, v.Id, v.Name, v.Type, strings.Join(fields, ", "))
+	}
+	table.Print()
+	fmt.Printf("\n%d added, %d removed, %d changed\n", counts[diff.Added], counts[diff.Removed], counts[diff.Changed])
+}
+
+func printDiffJson(from, to time.Time, changes diff.Changes) {
+	b, err := json.MarshalIndent(struct {
+		From    string       `json:"from"`
+		To      string       `json:"to"`
+		Changes diff.Changes `json:"changes"`
+	}{
+		From:    formatSnapshotTime(from),
+		To:      formatSnapshotTime(to),
+		Changes: changes,
+	}, "", "  ")
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(string(b))
+}
+
+func formatSnapshotTime(t time.Time) string {
+	if t.IsZero() {
+		return "latest"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
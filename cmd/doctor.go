@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/store"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+)
+
+var (
+	doctorFlags flag.Doctor
+	doctorCmd   = &cobra.Command{
+		Use:   "doctor",
+		Short: "check integrity of imported data, and aws access of import",
+		Long: `doctor checks that stored accounts and snapshots can be read: account files, schema version, resource files
+and duplicate accounts. With --aws flag, it checks that current aws credentials can call every aws api that import
+needs. Every problem is printed with suggested fix, doctor exits with non-zero code if any check failed.`,
+		Args: cobra.NoArgs,
+		Run:  runDoctor,
+	}
+)
+
+func init() {
+	flag.InitDoctorFlags(doctorCmd, &doctorFlags)
+	Root.AddCommand(doctorCmd)
+}
+
+func runDoctor(_ *cobra.Command, _ []string) {
+	opts := storeOptions()
+	unlock, err := store.RLock(opts)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	defer unlock()
+	checks, err := store.CheckStore(opts)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	failed := printStoreChecks(checks)
+
+	if doctorFlags.Aws {
+		fmt.Println()
+		failed += printAccessChecks()
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d checks failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("\nall checks passed")
+}
+
+// printStoreChecks prints result of store checks and their problems, number of failed checks is returned
+func printStoreChecks(checks []store.Check) int {
+	var failed int
+	table := NewTable()
+	table.AddRow("CHECK", "STATUS", "PROBLEMS")
+	for _, v := range checks {
+		if !v.Passed() {
+			failed++
+		}
+		table.AddRow(v.Name, checkStatus(v.Passed()), strconv.Itoa(len(v.Problems)))
+	}
+	table.Print()
+
+	// problems are printed separately, they are too long for the table
+	for _, v := range checks {
+		for _, problem := range v.Problems {
+			fmt.Printf("\n%s: %s: %s\n  fix: %s\n", v.Name, problem.Path, problem.Message, problem.Fix)
+		}
+	}
+	return failed
+}
+
+// printAccessChecks checks and prints aws api calls of import, number of failed checks is returned
+func printAccessChecks() int {
+	checks, err := store.CheckAccess("", store.ImportOptions{
+		Regions:     regionFlag(doctorFlags.Region),
+		EndpointUrl: doctorFlags.EndpointUrl,
+		Timeout:     doctorFlags.Timeout,
+	})
+	if err != nil {
+		fmt.Printf("aws: %v\n  fix: check aws config and credentials, e.g. AWS_PROFILE and AWS_REGION env. variables\n", err)
+		return 1
+	}
+
+	var failed int
+	table := NewTable()
+	table.AddRow("AWS ACTION", "REGION", "STATUS")
+	for _, v := range checks {
+		if !v.Allowed() {
+			failed++
+		}
+		table.AddRow(v.Action, v.Region, checkStatus(v.Allowed()))
+	}
+	table.Print()
+
+	for _, v := range checks {
+		if !v.Allowed() {
+			fmt.Printf("\n%s: %v\n  fix: %s\n", v.Action, v.Err, v.Fix())
+		}
+	}
+	return failed
+}
+
+func regionFlag(region string) []string {
+	if region == "" {
+		return nil
+	}
+	return []string{region}
+}
+
+func checkStatus(passed bool) string {
+	if passed {
+		return "pass"
+	}
+	return "fail"
+}
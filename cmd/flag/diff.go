@@ -0,0 +1,22 @@
+package flag
+
+import "github.com/spf13/cobra"
+
+type Diff struct {
+	Since  Duration
+	Output string
+}
+
+func InitDiffFlags(cmd *cobra.Command, flags *Diff) {
+	cmd.Flags().Var(
+		&flags.Since,
+		"since",
+		"compare snapshot current at supplied time ago (e.g. 7d) with the latest import, default is 7d",
+	)
+	cmd.Flags().StringVar(
+		&flags.Output,
+		"output",
+		"table",
+		"diff output format, table or json",
+	)
+}
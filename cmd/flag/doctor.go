@@ -0,0 +1,41 @@
+package flag
+
+import (
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+type Doctor struct {
+	Aws         bool
+	Region      string
+	EndpointUrl string
+	Timeout     time.Duration
+}
+
+func InitDoctorFlags(cmd *cobra.Command, flags *Doctor) {
+	cmd.Flags().BoolVar(
+		&flags.Aws,
+		"aws",
+		false,
+		"check that current aws credentials can call every aws api that import needs",
+	)
+	cmd.Flags().StringVar(
+		&flags.Region,
+		"region",
+		"",
+		"aws region of api calls, default is region from aws config, used with --aws",
+	)
+	cmd.Flags().StringVar(
+		&flags.EndpointUrl,
+		"endpoint-url",
+		os.Getenv("AWS_ENDPOINT_URL"),
+		"aws endpoint url (e.g. local moto server), can be set by AWS_ENDPOINT_URL env. var., used with --aws",
+	)
+	cmd.Flags().DurationVar(
+		&flags.Timeout,
+		"timeout",
+		30*time.Second,
+		"timeout of a single aws api call, used with --aws",
+	)
+}
@@ -0,0 +1,55 @@
+package flag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is time.Duration flag, that accepts days as well (e.g. 7d), since that is what we use for data age
+type Duration time.Duration
+
+func (d *Duration) String() string {
+	v := time.Duration(*d)
+	if v != 0 && v%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", v/(24*time.Hour))
+	}
+	return v.String()
+}
+
+func (d *Duration) Set(in string) error {
+	v, err := ParseDuration(in)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d *Duration) Type() string {
+	return "duration"
+}
+
+// ParseDuration parses go duration (e.g. 12h) or number of days (e.g. 7d), negative duration is invalid
+func ParseDuration(in string) (time.Duration, error) {
+	v, err := parseDuration(in)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("invalid duration %s, duration cannot be negative", in)
+	}
+	return v, nil
+}
+
+func parseDuration(in string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(in, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", in)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(in)
+}
@@ -0,0 +1,38 @@
+package flag
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected time.Duration
+	}{
+		{in: "7d", expected: 7 * 24 * time.Hour},
+		{in: "0d", expected: 0},
+		{in: "36h", expected: 36 * time.Hour},
+		{in: "90m", expected: 90 * time.Minute},
+	}
+
+	for _, test := range tests {
+		out, err := ParseDuration(test.in)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, out)
+	}
+
+	for _, in := range []string{"xd", "-1d", "-12h"} {
+		_, err := ParseDuration(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestDuration_String(t *testing.T) {
+	d := Duration(48 * time.Hour)
+	assert.Equal(t, "2d", d.String())
+	d = Duration(90 * time.Minute)
+	assert.Equal(t, "1h30m0s", d.String())
+}
@@ -3,7 +3,12 @@ package flag
 import "github.com/spf13/cobra"
 
 type Global struct {
-	Trim bool
+	Trim       bool
+	Strict     bool
+	MaxAge     Duration
+	StoreDir   string
+	Workspaces []string
+	Wait       bool
 }
 
 func InitPersistentFlags(cmd *cobra.Command, flags *Global) {
@@ -13,4 +18,33 @@ func InitPersistentFlags(cmd *cobra.Command, flags *Global) {
 		true,
 		"trim output",
 	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.Strict,
+		"strict",
+		false,
+		"fail on the first problem with stored data, instead of skipping it with warning",
+	)
+	cmd.PersistentFlags().Var(
+		&flags.MaxAge,
+		"max-age",
+		"report imported data older than max age (e.g. 12h or 7d) as stale, default is max_age from config or 7d",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.StoreDir,
+		"store-dir",
+		"",
+		"awf directory with imported data and config, default is AWF_HOME env. var. or $HOME/.awf",
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&flags.Workspaces,
+		"workspace",
+		nil,
+		"comma separated list of workspaces, or 'all' for all workspaces, import requires single workspace",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.Wait,
+		"wait",
+		false,
+		"wait for running import, instead of failing (searches, store remove and prune)",
+	)
 }
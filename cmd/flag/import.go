@@ -1,9 +1,27 @@
 package flag
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
 
 type Import struct {
-	Region string
+	Region      string
+	Regions     []string
+	AllProfiles bool
+	Include     []string
+	Exclude     []string
+	Workers     int
+	Org         bool
+	RoleName    string
+	EndpointUrl string
+	Timeout     time.Duration
+	MaxRetries  int
+	Output      string
+	KeepCount   int
+	KeepAge     Duration
+	Raw         bool
 }
 
 func InitImportFlags(cmd *cobra.Command, flags *Import) {
@@ -11,6 +29,98 @@ func InitImportFlags(cmd *cobra.Command, flags *Import) {
 		&flags.Region,
 		"region",
 		"",
-		"aws region",
+		"aws region, alias of --regions with single region",
 	)
+	cmd.Flags().StringSliceVar(
+		&flags.Regions,
+		"regions",
+		nil,
+		"comma separated list of aws regions, or 'all' for all enabled regions",
+	)
+	cmd.Flags().BoolVar(
+		&flags.AllProfiles,
+		"all-profiles",
+		false,
+		"import all profiles from shared aws config and credentials files",
+	)
+	cmd.Flags().StringSliceVar(
+		&flags.Include,
+		"include",
+		nil,
+		"profiles (glob pattern) to import, used with --all-profiles",
+	)
+	cmd.Flags().StringSliceVar(
+		&flags.Exclude,
+		"exclude",
+		nil,
+		"profiles (glob pattern) to skip, used with --all-profiles",
+	)
+	cmd.Flags().IntVar(
+		&flags.Workers,
+		"workers",
+		4,
+		"number of profiles or accounts imported concurrently, used with --all-profiles or --org",
+	)
+	cmd.Flags().BoolVar(
+		&flags.Org,
+		"org",
+		false,
+		"import all accounts in aws organization, current profile has to belong to the management account",
+	)
+	cmd.Flags().StringVar(
+		&flags.RoleName,
+		"role-name",
+		"OrganizationAccountAccessRole",
+		"role assumed in organization accounts, used with --org",
+	)
+	cmd.Flags().StringVar(
+		&flags.EndpointUrl,
+		"endpoint-url",
+		os.Getenv("AWS_ENDPOINT_URL"),
+		"aws endpoint url (e.g. local moto server), can be set by AWS_ENDPOINT_URL env. var.",
+	)
+	cmd.Flags().DurationVar(
+		&flags.Timeout,
+		"timeout",
+		30*time.Second,
+		"timeout of a single aws api call (page), including retries",
+	)
+	cmd.Flags().IntVar(
+		&flags.MaxRetries,
+		"max-retries",
+		8,
+		"maximum number of retries of failed (e.g. throttled) aws api call",
+	)
+	cmd.Flags().StringVar(
+		&flags.Output,
+		"output",
+		"table",
+		"import result output format, table or json",
+	)
+	cmd.Flags().IntVar(
+		&flags.KeepCount,
+		"keep-snapshots",
+		30,
+		"number of snapshots (imports) kept per account and region, 0 keeps all snapshots",
+	)
+	cmd.Flags().Var(
+		&flags.KeepAge,
+		"keep-age",
+		"remove snapshots older than supplied duration (e.g. 90d), 0 keeps snapshots regardless of age",
+	)
+	cmd.Flags().BoolVar(
+		&flags.Raw,
+		"raw",
+		false,
+		"store raw aws api responses as well, for troubleshooting",
+	)
+	cmd.MarkFlagsMutuallyExclusive("all-profiles", "org")
+}
+
+// ImportRegions returns regions from --regions and --region (alias) flags
+func (i Import) ImportRegions() []string {
+	if i.Region == "" {
+		return i.Regions
+	}
+	return append([]string{i.Region}, i.Regions...)
 }
@@ -0,0 +1,30 @@
+package flag
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestImport_ImportRegions(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{args: nil, expected: nil},
+		{args: []string{"--region", "eu-west-1"}, expected: []string{"eu-west-1"}},
+		{args: []string{"--regions", "eu-west-1,eu-west-2"}, expected: []string{"eu-west-1", "eu-west-2"}},
+		{args: []string{"--region", "eu-west-1", "--regions", "eu-west-2"}, expected: []string{"eu-west-1", "eu-west-2"}},
+	}
+
+	for _, test := range tests {
+		var flags Import
+		cmd := &cobra.Command{}
+		InitImportFlags(cmd, &flags)
+		require.NoError(t, cmd.ParseFlags(test.args))
+		assert.Equal(t, test.expected, flags.ImportRegions(), test.args)
+		// --region is alias, not deprecated flag
+		assert.Empty(t, cmd.Flags().Lookup("region").Deprecated)
+	}
+}
@@ -0,0 +1,24 @@
+package flag
+
+import "github.com/spf13/cobra"
+
+type IamPolicy struct {
+	Output     string
+	AllRegions bool
+}
+
+func InitIamPolicyFlags(cmd *cobra.Command, flags *IamPolicy) {
+	cmd.Flags().StringVarP(
+		&flags.Output,
+		"output",
+		"o",
+		"json",
+		"output format, json (policy document), terraform or cloudformation",
+	)
+	cmd.Flags().BoolVar(
+		&flags.AllRegions,
+		"all-regions",
+		false,
+		"allow import of all regions ('awf import --regions all'), it needs ec2:DescribeRegions",
+	)
+}
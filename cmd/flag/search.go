@@ -0,0 +1,15 @@
+package flag
+
+import "github.com/spf13/cobra"
+
+type Search struct {
+	At Time
+}
+
+func InitSearchFlags(cmd *cobra.Command, flags *Search) {
+	cmd.Flags().Var(
+		&flags.At,
+		"at",
+		"search snapshot that was current at supplied time (e.g. 2026-10-01T12:00Z), default is the latest import",
+	)
+}
@@ -0,0 +1,119 @@
+package flag
+
+import "github.com/spf13/cobra"
+
+type StoreExport struct {
+	Output    string
+	Accounts  []string
+	Regions   []string
+	Resources []string
+}
+
+func InitStoreExportFlags(cmd *cobra.Command, flags *StoreExport) {
+	cmd.Flags().StringVarP(
+		&flags.Output,
+		"output",
+		"o",
+		"",
+		"bundle file, e.g. inventory.tar.zst",
+	)
+	cmd.MarkFlagRequired("output")
+	cmd.Flags().StringSliceVar(
+		&flags.Accounts,
+		"accounts",
+		nil,
+		"comma separated list of account IDs to export, default is all accounts",
+	)
+	cmd.Flags().StringSliceVar(
+		&flags.Regions,
+		"regions",
+		nil,
+		"comma separated list of regions to export, default is all regions",
+	)
+	cmd.Flags().StringSliceVar(
+		&flags.Resources,
+		"resources",
+		nil,
+		"comma separated list of resources (vpc, subnet, ni) to export, default is all resources",
+	)
+}
+
+type StoreRemove struct {
+	Accounts  []string
+	Regions   []string
+	OlderThan Duration
+	All       bool
+	DryRun    bool
+}
+
+func InitStoreRemoveFlags(cmd *cobra.Command, flags *StoreRemove) {
+	cmd.Flags().StringSliceVar(
+		&flags.Accounts,
+		"accounts",
+		nil,
+		"comma separated list of account IDs to remove",
+	)
+	cmd.Flags().StringSliceVar(
+		&flags.Regions,
+		"regions",
+		nil,
+		"comma separated list of regions to remove, whole accounts are removed if not set",
+	)
+	cmd.Flags().Var(
+		&flags.OlderThan,
+		"older-than",
+		"remove accounts (or regions) that were not imported within supplied duration (e.g. 30d)",
+	)
+	cmd.Flags().BoolVar(
+		&flags.All,
+		"all",
+		false,
+		"remove all imported data",
+	)
+	cmd.Flags().BoolVar(
+		&flags.DryRun,
+		"dry-run",
+		false,
+		"print what would be removed, without removing it",
+	)
+}
+
+type StorePrune struct {
+	Accounts  []string
+	Regions   []string
+	KeepCount int
+	KeepAge   Duration
+	DryRun    bool
+}
+
+func InitStorePruneFlags(cmd *cobra.Command, flags *StorePrune) {
+	cmd.Flags().StringSliceVar(
+		&flags.Accounts,
+		"accounts",
+		nil,
+		"comma separated list of account IDs to prune, default is all accounts",
+	)
+	cmd.Flags().StringSliceVar(
+		&flags.Regions,
+		"regions",
+		nil,
+		"comma separated list of regions to prune, default is all regions",
+	)
+	cmd.Flags().IntVar(
+		&flags.KeepCount,
+		"keep-snapshots",
+		0,
+		"number of snapshots (imports) kept per account and region",
+	)
+	cmd.Flags().Var(
+		&flags.KeepAge,
+		"keep-age",
+		"remove snapshots older than supplied duration (e.g. 90d)",
+	)
+	cmd.Flags().BoolVar(
+		&flags.DryRun,
+		"dry-run",
+		false,
+		"print what would be removed, without removing it",
+	)
+}
@@ -0,0 +1,51 @@
+package flag
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeFormats are accepted snapshot time formats, times without zone are UTC
+var timeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	time.DateTime,
+	time.DateOnly,
+	"20060102T150405.000Z",
+}
+
+// ParseTime parses snapshot time, e.g. 2026-10-01T12:00Z, 2026-10-01 or snapshot name (20261001T120000.000Z)
+func ParseTime(in string) (time.Time, error) {
+	for _, layout := range timeFormats {
+		if t, err := time.Parse(layout, in); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s, use e.g. 2026-10-01T12:00Z or 2026-10-01", in)
+}
+
+// Time is time flag, that accepts formats supported by ParseTime
+type Time time.Time
+
+func (t *Time) String() string {
+	v := time.Time(*t)
+	if v.IsZero() {
+		return ""
+	}
+	return v.Format(time.RFC3339)
+}
+
+func (t *Time) Set(in string) error {
+	v, err := ParseTime(in)
+	if err != nil {
+		return err
+	}
+	*t = Time(v)
+	return nil
+}
+
+func (t *Time) Type() string {
+	return "time"
+}
@@ -0,0 +1,31 @@
+package flag
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected time.Time
+	}{
+		{in: "2026-10-01T12:00Z", expected: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{in: "2026-10-01T14:00+02:00", expected: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{in: "2026-10-01T12:00:30Z", expected: time.Date(2026, 10, 1, 12, 0, 30, 0, time.UTC)},
+		{in: "2026-10-01 12:00:30", expected: time.Date(2026, 10, 1, 12, 0, 30, 0, time.UTC)},
+		{in: "2026-10-01", expected: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "20261001T120000.123Z", expected: time.Date(2026, 10, 1, 12, 0, 0, 123000000, time.UTC)},
+	}
+
+	for _, test := range tests {
+		out, err := ParseTime(test.in)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.expected, out, test.in)
+	}
+
+	_, err := ParseTime("yesterday")
+	assert.Error(t, err)
+}
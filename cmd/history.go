@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pete911/awf/internal/history"
+	"github.com/pete911/awf/internal/types"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+var (
+	historyCmd = &cobra.Command{
+		Use:   "history <IP>",
+		Short: "show timeline of network interfaces that held the IP in stored snapshots",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   runHistory,
+	}
+)
+
+func init() {
+	Root.AddCommand(historyCmd)
+}
+
+func runHistory(_ *cobra.Command, args []string) {
+	ip := args[0]
+	if !IsIP(ip) {
+		fmt.Printf("argument %s is not IP\n", ip)
+		os.Exit(1)
+	}
+
+	dataStore, unlock := LoadStore()
+	defer unlock()
+	timeline := history.NewTimeline(ip)
+	var snapshots int
+	err := dataStore.WalkNetworkInterfaces(func(account types.Account, region string, snapshot time.Time, nis types.NetworkInterfaces) {
+		snapshots++
+		timeline.Add(account.Id, region, snapshot, nis)
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	entries := timeline.Entries()
+	if len(entries) == 0 {
+		fmt.Printf("searched %d snapshots, but no network interface held %s\n", snapshots, ip)
+		PrintWarnings(dataStore)
+		return
+	}
+
+	printHistory(entries)
+	PrintWarnings(dataStore)
+}
+
+func printHistory(entries []history.Entry) {
+	table := NewTable()
+	table.AddRow("ACCOUNT ID", "AWS PROFILE", "REGION", "ENI", "TYPE", "DESCRIPTION", "INSTANCE ID", "FIRST SEEN", "LAST SEEN")
+	for _, v := range entries {
+		lastSeen := v.LastSeen.Local().Format(time.DateTime)
+		if v.Current {
+			lastSeen = "current"
+		}
+		table.AddRow(
+			v.NetworkInterface.Account.Id,
+			v.NetworkInterface.Account.Profile,
+			v.NetworkInterface.Region,
+			v.NetworkInterface.NetworkInterfaceId,
+			v.NetworkInterface.Type,
+			v.NetworkInterface.Description,
+			v.NetworkInterface.InstanceId,
+			v.FirstSeen.Local().Format(time.DateTime),
+			lastSeen,
+		)
+	}
+	table.Print()
+}
@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/out"
 	"github.com/pete911/awf/internal/store"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
+)
+
+const (
+	// exitPartialImport is returned when some, but not all, importers failed
+	exitPartialImport = 2
 )
 
 var (
@@ -24,8 +32,101 @@ func init() {
 }
 
 func runImport(cmd *cobra.Command, _ []string) {
-	if err := store.Import(importFlags.Region); err != nil {
+	if importFlags.Output != "table" && importFlags.Output != "json" {
+		fmt.Printf("invalid output %s, only table or json is supported\n", importFlags.Output)
+		os.Exit(1)
+	}
+
+	// import writes to single workspace
+	WorkspaceDir()
+
+	var result store.ImportResult
+	switch {
+	case importFlags.Org:
+		result = store.ImportOrganization("", importFlags.RoleName, importOptions())
+	case importFlags.AllProfiles:
+		profiles, err := store.ListProfiles(importFlags.Include, importFlags.Exclude)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if len(profiles) == 0 {
+			fmt.Println("no aws profiles found")
+			os.Exit(1)
+		}
+		result = store.ImportProfiles(profiles, importOptions())
+	default:
+		result = store.Import("", importOptions())
+	}
+
+	if importFlags.Output == "json" {
+		printImportResultJson(result)
+	} else {
+		printImportResult(result)
+	}
+
+	switch result.Status() {
+	case store.ImportFailed:
+		os.Exit(1)
+	case store.ImportPartial:
+		os.Exit(exitPartialImport)
+	}
+}
+
+func importOptions() store.ImportOptions {
+	return store.ImportOptions{
+		Regions:     importFlags.ImportRegions(),
+		EndpointUrl: importFlags.EndpointUrl,
+		Workers:     importFlags.Workers,
+		Timeout:     importFlags.Timeout,
+		MaxRetries:  importFlags.MaxRetries,
+		Raw:         importFlags.Raw,
+		Store:       LoadWriteStore(),
+		Retention: store.Retention{
+			Count:  importFlags.KeepCount,
+			MaxAge: time.Duration(importFlags.KeepAge),
+		},
+	}
+}
+
+func printImportResult(result store.ImportResult) {
+	table := NewTable()
+	table.AddRow("AWS PROFILE", "ACCOUNT ID", "REGION", "IMPORTER", "STATUS", "DURATION", "ITEMS", "PAGES", "RETRIES")
+	for _, v := range result {
+		table.AddRow(
+			v.Profile,
+			v.AccountId,
+			v.Region,
+			v.Importer,
+			string(v.Status()),
+			v.Duration.Round(time.Millisecond).String(),
+			out.FromInt(v.Items),
+			out.FromInt(v.Pages),
+			out.FromInt(v.Retries),
+		)
+	}
+	table.Print()
+
+	// errors are printed separately, they are too long for the table
+	for _, v := range result {
+		if v.Err != nil {
+			fmt.Printf("%s %s %s %s: %v\n", v.Profile, v.AccountId, v.Region, v.Importer, v.Err)
+		}
+	}
+	fmt.Printf("import %s, %d of %d importers failed\n", result.Status(), result.Failed(), len(result))
+}
+
+func printImportResultJson(result store.ImportResult) {
+	b, err := json.MarshalIndent(struct {
+		Status  store.ImportStatus `json:"status"`
+		Entries store.ImportResult `json:"entries"`
+	}{
+		Status:  result.Status(),
+		Entries: result,
+	}, "", "  ")
+	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
+	fmt.Println(string(b))
 }
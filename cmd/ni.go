@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/store"
 	"github.com/pete911/awf/internal/types"
 	"github.com/spf13/cobra"
+	"net/netip"
 	"os"
 	"strings"
 )
@@ -19,6 +22,7 @@ var (
 
 func init() {
 	Root.AddCommand(niCmd)
+	flag.InitSearchFlags(niCmd, &searchFlags)
 }
 
 func runNi(cmd *cobra.Command, args []string) {
@@ -27,20 +31,28 @@ func runNi(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fileStore := LoadFileStore()
-	vpcs, err := fileStore.DescribeVpcs()
+	dataStore, unlock := LoadSearchStore()
+	defer unlock()
+	vpcs, err := dataStore.DescribeVpcs()
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
-	sunbets, err := fileStore.DescribeSubnets()
+	sunbets, err := dataStore.DescribeSubnets()
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
-	nis, err := fileStore.DescribeNetworkInterfaces()
+	// network interfaces are read once for all id arguments
+	var ids []string
+	for _, arg := range args {
+		if IsEniId(arg) {
+			ids = append(ids, arg)
+		}
+	}
+	byId, err := dataStore.GetNetworkInterfacesById(ids...)
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
@@ -48,17 +60,35 @@ func runNi(cmd *cobra.Command, args []string) {
 
 	var matched types.NetworkInterfaces
 	for _, arg := range args {
-		if nis := findNetworkInterfaces(arg, nis); len(nis) > 0 {
-			matched = append(matched, nis...)
+		nis, err := findNetworkInterfaces(arg, byId, dataStore)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		matched = append(matched, nis...)
 	}
 
 	if len(matched) == 0 {
-		fmt.Printf("searched %d network interfaces, but none matched\n", len(nis))
+		counts, err := store.CountItems(dataStore)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("searched %d network interfaces, but none matched\n", counts.NetworkInterfaces)
+		PrintWarnings(dataStore)
 		return
 	}
 
+	var regions []AccountRegion
+	for _, v := range matched {
+		regions = append(regions, AccountRegion{Account: v.Account, Region: v.Region})
+	}
+	stale := CheckStale(dataStore, regions)
+
 	printNi(matched, vpcs, sunbets)
+	PrintStale(stale)
+	PrintSnapshots(dataStore, regions)
+	PrintWarnings(dataStore)
 }
 
 func printNi(nis types.NetworkInterfaces, vpcs types.Vpcs, subnets types.Subnets) {
@@ -92,17 +122,23 @@ func printNi(nis types.NetworkInterfaces, vpcs types.Vpcs, subnets types.Subnets
 	table.Print()
 }
 
-func findNetworkInterfaces(arg string, nis types.NetworkInterfaces) []types.NetworkInterface {
+// findNetworkInterfaces finds network interfaces by id (in network interfaces read by id arguments), or by IP and CIDR
+// using store lookups, that use store indexes
+func findNetworkInterfaces(arg string, byId types.NetworkInterfaces, dataStore store.Store) (types.NetworkInterfaces, error) {
 	if IsIP(arg) {
-		return nis.GetByIp(arg)
+		return dataStore.GetNetworkInterfacesByIp(arg)
 	}
 	if IsEniId(arg) {
-		return nis.GetById(arg)
+		return byId.GetById(arg), nil
 	}
 	if IsCIDR(arg) {
-		return nis.GetByCidr(arg)
+		result, err := dataStore.Lookup(netip.MustParsePrefix(arg))
+		if err != nil {
+			return nil, err
+		}
+		return result.NetworkInterfaces, nil
 	}
 	fmt.Printf("argument %s can only be IP, CIDR, or network interface id", arg)
 	os.Exit(1)
-	return nil
+	return nil, nil
 }
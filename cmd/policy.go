@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/policy"
+	"github.com/pete911/awf/internal/store"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	iamPolicyFlags flag.IamPolicy
+	iamPolicyCmd   = &cobra.Command{
+		Use:   "iam-policy",
+		Short: "print minimal IAM policy that import needs",
+		Long: `print IAM policy with actions of every aws api call that 'awf import' makes, attach it to the role (or user)
+used by import. Policy is printed as json policy document, or as terraform or cloudformation snippet (--output flag).
+Import of organization (--org) needs organizations:ListAccounts and sts:AssumeRole in the management account as well.`,
+		Args: cobra.NoArgs,
+		Run:  runIamPolicy,
+	}
+)
+
+func init() {
+	flag.InitIamPolicyFlags(iamPolicyCmd, &iamPolicyFlags)
+	Root.AddCommand(iamPolicyCmd)
+}
+
+func runIamPolicy(_ *cobra.Command, _ []string) {
+	p := policy.New(store.ImportActions(iamPolicyFlags.AllRegions))
+	switch iamPolicyFlags.Output {
+	case "json":
+		out, err := p.Json()
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(out)
+	case "terraform":
+		fmt.Print(p.Terraform())
+	case "cloudformation":
+		fmt.Print(p.CloudFormation())
+	default:
+		fmt.Printf("invalid output %s, only json, terraform or cloudformation is supported\n", iamPolicyFlags.Output)
+		os.Exit(1)
+	}
+}
@@ -4,18 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/config"
 	"github.com/pete911/awf/internal/out"
 	"github.com/pete911/awf/internal/store"
 	"github.com/spf13/cobra"
 	"net/netip"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
 	GlobalFlags flag.Global
 	Root        = &cobra.Command{}
 	Version     string
+
+	configOnce sync.Once
+	loadedCfg  config.Config
 )
 
 func init() {
@@ -26,8 +32,42 @@ func NewTable() out.Table {
 	return out.NewTable(os.Stdout, GlobalFlags.Trim)
 }
 
-func LoadFileStore() store.File {
-	fileStorage, err := store.LoadFile()
+// LoadStore returns store backend set in config (file store by default) for commands that read the store. Store is
+// read locked, so running import or removal cannot change data that are being read (see store.RLock). Returned
+// function releases the lock, commands defer it, so the lock is held until the command finishes.
+func LoadStore() (store.Store, func() error) {
+	opts := storeOptions()
+	unlock, err := store.RLock(opts)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return loadStore(opts), unlock
+}
+
+// LoadWriteStore returns store backend set in config for commands that change the store (e.g. import), store is not
+// read locked, writes take their own locks. Store key of passphrase encrypted store is created, if it does not exist.
+func LoadWriteStore() store.Store {
+	opts := storeOptions()
+	opts.Encoding.CreateKey = true
+	return loadStore(opts)
+}
+
+func storeOptions() store.Options {
+	cfg := LoadConfig()
+	return store.Options{
+		Backend:         cfg.Store,
+		Dir:             StoreDir(),
+		Workspaces:      GlobalFlags.Workspaces,
+		Strict:          GlobalFlags.Strict,
+		Encoding:        StoreEncoding(cfg),
+		ProfilePriority: cfg.ProfilePriority,
+		Wait:            GlobalFlags.Wait,
+	}
+}
+
+func loadStore(opts store.Options) store.Store {
+	dataStore, err := store.Load(opts)
 	var notFound *store.NotFoundError
 	if err != nil {
 		if errors.As(err, &notFound) {
@@ -37,7 +77,80 @@ func LoadFileStore() store.File {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
-	return fileStorage
+	return dataStore
+}
+
+// StoreEncoding returns compression from config, and encryption passphrase or key file from env. variables or config
+func StoreEncoding(cfg config.Config) store.Encoding {
+	return store.EncodingFromEnv(cfg.Compression, cfg.KeyFile)
+}
+
+// StoreDir returns awf directory set by --store-dir flag, AWF_HOME env. variable, or default $HOME/.awf directory
+func StoreDir() string {
+	if GlobalFlags.StoreDir != "" {
+		return GlobalFlags.StoreDir
+	}
+	dir, err := store.Dir()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return dir
+}
+
+// WorkspaceDir returns store directory of the workspace set by --workspace flag, it fails if more than one workspace
+// is selected
+func WorkspaceDir() string {
+	dirs, err := store.ResolveWorkspaces(StoreDir(), GlobalFlags.Workspaces)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if len(dirs) != 1 {
+		fmt.Println("select single workspace, e.g. --workspace prod")
+		os.Exit(1)
+	}
+	return dirs[0]
+}
+
+// LoadConfig returns config from awf directory, default config is returned if the config cannot be loaded. Config is
+// shared by all workspaces and it is loaded once per command, load error is printed to stderr.
+func LoadConfig() config.Config {
+	configOnce.Do(func() {
+		cfg, err := config.Load(StoreDir())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+			return
+		}
+		loadedCfg = cfg
+	})
+	return loadedCfg
+}
+
+// PrintWarnings prints problems with stored data that were skipped when reading, warnings are printed to stderr, so
+// they do not break json output
+func PrintWarnings(dataStore store.Store) {
+	warnings := dataStore.Warnings()
+	if len(warnings) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "\nwarnings, some stored data were skipped (use --strict to fail instead, run 'awf doctor' for fixes):")
+	for _, v := range warnings {
+		fmt.Fprintf(os.Stderr, "  - %s\n", v)
+	}
+}
+
+// FormatAge formats duration rounded to days, hours or minutes
+func FormatAge(in time.Duration) string {
+	switch {
+	case in >= 24*time.Hour:
+		return fmt.Sprintf("%dd", in/(24*time.Hour))
+	case in >= time.Hour:
+		return fmt.Sprintf("%dh", in/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", in/time.Minute)
+	}
 }
 
 func IsVpcId(in string) bool {
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/store"
+	"time"
+)
+
+var searchFlags flag.Search
+
+// SearchAt returns time set by --at flag, zero time if search commands read the latest import
+func SearchAt() time.Time {
+	return time.Time(searchFlags.At)
+}
+
+// LoadSearchStore returns store that reads snapshots current at the time set by --at flag, see LoadStore
+func LoadSearchStore() (store.Store, func() error) {
+	dataStore, unlock := LoadStore()
+	return dataStore.At(SearchAt()), unlock
+}
+
+// PrintSnapshots prints footer with snapshots (of matched results) that were used, if --at flag is set
+func PrintSnapshots(dataStore store.Store, regions []AccountRegion) {
+	at := SearchAt()
+	if at.IsZero() {
+		return
+	}
+
+	fmt.Printf("\nresults from snapshots current at %s:\n", at.Format(time.RFC3339))
+	printed := make(map[string]struct{})
+	for _, v := range regions {
+		key := v.Account.Id + "/" + v.Region
+		if _, ok := printed[key]; ok {
+			continue
+		}
+		printed[key] = struct{}{}
+
+		snapshot, ok := dataStore.Snapshot(v.Account, v.Region)
+		if !ok {
+			continue
+		}
+		fmt.Printf("  - %s %s %s snapshot %s\n", v.Account.Id, v.Account.Profile, v.Region, snapshot.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/store"
+	"github.com/pete911/awf/internal/types"
+	"os"
+	"time"
+)
+
+const defaultMaxAge = 7 * 24 * time.Hour
+
+// AccountRegion identifies imported region of an account, search results are mapped to it to check data age
+type AccountRegion struct {
+	Account types.Account
+	Region  string
+}
+
+type StaleRegion struct {
+	AccountRegion
+	ImportedAt time.Time
+}
+
+// MaxAge returns age after which imported data are stale, from --max-age flag, config file, or default (7 days)
+func MaxAge() time.Duration {
+	if Root.PersistentFlags().Changed("max-age") {
+		return time.Duration(GlobalFlags.MaxAge)
+	}
+
+	cfg := LoadConfig()
+	if cfg.MaxAge == "" {
+		return defaultMaxAge
+	}
+	maxAge, err := flag.ParseDuration(cfg.MaxAge)
+	if err != nil {
+		fmt.Printf("config max_age: %v\n", err)
+		return defaultMaxAge
+	}
+	return maxAge
+}
+
+// CheckStale returns regions (of matched results) that were imported more than max age ago. If --max-age and
+// --strict flags are set, the command fails, so scripts do not act on stale data. Searches of past snapshots are
+// not checked.
+func CheckStale(dataStore store.Store, regions []AccountRegion) []StaleRegion {
+	// snapshot age is expected when searching past snapshots (--at flag)
+	if !SearchAt().IsZero() {
+		return nil
+	}
+
+	maxAge := MaxAge()
+	var stale []StaleRegion
+	checked := make(map[string]struct{})
+	for _, v := range regions {
+		key := v.Account.Id + "/" + v.Region
+		if _, ok := checked[key]; ok {
+			continue
+		}
+		checked[key] = struct{}{}
+
+		importedAt, err := dataStore.ImportedAt(v.Account, v.Region)
+		if err != nil || importedAt.IsZero() {
+			continue
+		}
+		if time.Since(importedAt) > maxAge {
+			stale = append(stale, StaleRegion{AccountRegion: v, ImportedAt: importedAt})
+		}
+	}
+
+	if len(stale) > 0 && GlobalFlags.Strict && Root.PersistentFlags().Changed("max-age") {
+		printStale(stale, maxAge)
+		os.Exit(1)
+	}
+	return stale
+}
+
+// PrintStale prints footer with regions that were imported more than max age ago
+func PrintStale(stale []StaleRegion) {
+	if len(stale) > 0 {
+		printStale(stale, MaxAge())
+	}
+}
+
+func printStale(stale []StaleRegion, maxAge time.Duration) {
+	fmt.Printf("\nresults from %d regions were imported more than %s ago, run 'awf import' to refresh them:\n",
+		len(stale), FormatAge(maxAge))
+	for _, v := range stale {
+		fmt.Printf("  - %s %s %s imported %s ago\n", v.Account.Id, v.Account.Profile, v.Region, FormatAge(time.Since(v.ImportedAt)))
+	}
+}
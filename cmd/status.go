@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/pete911/awf/internal/out"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+var (
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "show imported accounts, regions and resources",
+		Long:  "",
+		Run:   runStatus,
+	}
+)
+
+func init() {
+	Root.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, _ []string) {
+	dataStore, unlock := LoadStore()
+	defer unlock()
+	accounts, err := dataStore.ListAccounts()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	maxAge := MaxAge()
+	var stale int
+	table := NewTable()
+	table.AddRow("ACCOUNT ID", "AWS PROFILE", "ALIAS", "REGION", "RESOURCE", "IMPORTED", "AGE", "ITEMS", "STALE")
+	for _, account := range accounts {
+		regions, err := dataStore.ListRegions(account)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if len(regions) == 0 {
+			table.AddRow(account.Id, account.Profile, account.Alias, "-", "-", "-", "-", "-", "-")
+			continue
+		}
+
+		for _, region := range regions {
+			resources, err := dataStore.ListResources(account, region)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if len(resources) == 0 {
+				table.AddRow(account.Id, account.Profile, account.Alias, region, "-", "-", "-", "-", "-")
+			}
+			for _, resource := range resources {
+				age := time.Since(resource.ImportedAt)
+				isStale := age > maxAge
+				if isStale {
+					stale++
+				}
+				items := "?"
+				if resource.Items >= 0 {
+					items = out.FromInt(resource.Items)
+				}
+				table.AddRow(
+					account.Id,
+					account.Profile,
+					account.Alias,
+					region,
+					resource.Name,
+					resource.ImportedAt.Local().Format(time.DateTime),
+					FormatAge(age),
+					items,
+					fmt.Sprintf("%t", isStale),
+				)
+			}
+		}
+	}
+	table.Print()
+
+	if stale > 0 {
+		fmt.Printf("\n%d resources were imported more than %s ago, run 'awf import' to refresh them\n", stale, FormatAge(maxAge))
+	}
+	PrintWarnings(dataStore)
+}
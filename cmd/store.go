@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/config"
+	"github.com/pete911/awf/internal/store"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	storeCmd = &cobra.Command{
+		Use:   "store",
+		Short: "manage store of imported data",
+		Long:  "",
+	}
+	storeMigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "copy imported data from file store (json files) to sqlite store",
+		Long: `copy accounts and snapshots from file store to sqlite database (awf.db in workspace directory),
+snapshots that are already in the database are skipped. Set "store": "sqlite" in config.json to use the database.`,
+		Args: cobra.NoArgs,
+		Run:  runStoreMigrate,
+	}
+	storeExportFlags flag.StoreExport
+	storeExportCmd   = &cobra.Command{
+		Use:   "export",
+		Short: "export imported data to bundle file, that can be shared and loaded by 'awf store load'",
+		Long: `export current imports of accounts and regions to zstd compressed tar bundle, with account metadata and
+manifest (import times and checksums of bundle files). Exported data can be limited by --accounts, --regions and
+--resources flags.`,
+		Args: cobra.NoArgs,
+		Run:  runStoreExport,
+	}
+	storeLoadCmd = &cobra.Command{
+		Use:   "load <bundle>",
+		Short: "merge bundle created by 'awf store export' into the store",
+		Long: `merge bundle created by 'awf store export' into the store, imports keep their original import time.
+Imports that are already in the store are skipped, account metadata (e.g. profile) of accounts that are already in the
+store are kept. Bundle is verified against manifest checksums before anything is written.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runStoreLoad,
+	}
+	storeRemoveFlags flag.StoreRemove
+	storeRemoveCmd   = &cobra.Command{
+		Use:   "remove",
+		Short: "remove imported accounts or regions",
+		Long: `remove imported accounts (or only their regions, if --regions flag is set) selected by --accounts,
+--regions and --older-than flags, or all imported data with --all flag. E.g. 'awf store remove --older-than 30d' removes
+accounts that were not imported within 30 days. Accounts and regions that are being imported are not removed.`,
+		Args: cobra.NoArgs,
+		Run:  runStoreRemove,
+	}
+	storePruneFlags flag.StorePrune
+	storePruneCmd   = &cobra.Command{
+		Use:   "prune",
+		Short: "remove old snapshots (imports) of accounts and regions",
+		Long: `remove snapshots over --keep-snapshots count or older than --keep-age, the latest import of every
+account and region is always kept. Accounts and regions that are being imported are not pruned.`,
+		Args: cobra.NoArgs,
+		Run:  runStorePrune,
+	}
+)
+
+func init() {
+	Root.AddCommand(storeCmd)
+	storeCmd.AddCommand(storeMigrateCmd)
+	flag.InitStoreExportFlags(storeExportCmd, &storeExportFlags)
+	storeCmd.AddCommand(storeExportCmd)
+	storeCmd.AddCommand(storeLoadCmd)
+	flag.InitStoreRemoveFlags(storeRemoveCmd, &storeRemoveFlags)
+	storeCmd.AddCommand(storeRemoveCmd)
+	flag.InitStorePruneFlags(storePruneCmd, &storePruneFlags)
+	storeCmd.AddCommand(storePruneCmd)
+}
+
+func runStoreMigrate(_ *cobra.Command, _ []string) {
+	dir := WorkspaceDir()
+	encoding := StoreEncoding(LoadConfig())
+	from, err := store.LoadFile(store.FileOptions{Dir: dir, Strict: GlobalFlags.Strict, Encoding: encoding})
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	// encrypted store cannot be migrated, sqlite store does not support encryption
+	to, err := store.LoadSqlite(store.SqliteOptions{Dir: dir, Strict: GlobalFlags.Strict, Encoding: encoding})
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	defer to.Close()
+
+	migrated, err := to.Migrate(from)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("migrated %d snapshots to sqlite store\n", migrated)
+	if LoadConfig().Store != store.SqliteBackend {
+		fmt.Printf("set \"store\": \"sqlite\" in %s to use it\n", config.Path(StoreDir()))
+	}
+	PrintWarnings(from)
+}
+
+func runStoreExport(_ *cobra.Command, _ []string) {
+	dataStore, unlock := LoadStore()
+	defer unlock()
+	filter := store.BundleFilter{
+		Accounts:  storeExportFlags.Accounts,
+		Regions:   storeExportFlags.Regions,
+		Resources: storeExportFlags.Resources,
+	}
+
+	f, err := os.OpenFile(storeExportFlags.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	manifest, err := store.ExportBundle(dataStore, f, filter)
+	if err = errors.Join(err, f.Close()); err != nil {
+		os.Remove(storeExportFlags.Output)
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if len(manifest.Snapshots) == 0 {
+		os.Remove(storeExportFlags.Output)
+		fmt.Println("no imported data matched the filter, nothing was exported")
+		os.Exit(1)
+	}
+	fmt.Printf("exported %d accounts and %d regions to %s\n",
+		len(manifest.Accounts()), len(manifest.Snapshots), storeExportFlags.Output)
+	PrintWarnings(dataStore)
+}
+
+func runStoreLoad(_ *cobra.Command, args []string) {
+	// load writes to single workspace
+	WorkspaceDir()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	bundle, err := store.ReadBundle(f)
+	f.Close()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	dataStore := LoadWriteStore()
+	result, err := bundle.Load(dataStore)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("loaded %d imports of %d accounts created at %s, skipped %d imports already in the store\n",
+		result.Loaded, len(bundle.Manifest.Accounts()), bundle.Manifest.CreatedAt.Local().Format(time.DateTime), result.Skipped)
+	PrintWarnings(dataStore)
+}
+
+func runStoreRemove(_ *cobra.Command, _ []string) {
+	filter := store.RemoveFilter{
+		Accounts:  storeRemoveFlags.Accounts,
+		Regions:   storeRemoveFlags.Regions,
+		OlderThan: time.Duration(storeRemoveFlags.OlderThan),
+	}
+	if !storeRemoveFlags.All && len(filter.Accounts) == 0 && len(filter.Regions) == 0 && filter.OlderThan == 0 {
+		fmt.Println("select data to remove by --accounts, --regions or --older-than flags, or remove everything with --all")
+		os.Exit(1)
+	}
+	// removal writes to single workspace
+	WorkspaceDir()
+
+	dataStore := LoadWriteStore()
+	removals, err := store.PlanRemove(dataStore, filter)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	applyRemovals(dataStore, removals, storeRemoveFlags.DryRun)
+}
+
+func runStorePrune(_ *cobra.Command, _ []string) {
+	// prune writes to single workspace
+	WorkspaceDir()
+
+	dataStore := LoadWriteStore()
+	removals, err := store.PlanPrune(dataStore, store.PruneOptions{
+		Accounts: storePruneFlags.Accounts,
+		Regions:  storePruneFlags.Regions,
+		Retention: store.Retention{
+			Count:  storePruneFlags.KeepCount,
+			MaxAge: time.Duration(storePruneFlags.KeepAge),
+		},
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	applyRemovals(dataStore, removals, storePruneFlags.DryRun)
+}
+
+// applyRemovals prints planned removals and removes them, unless dry run is set
+func applyRemovals(dataStore store.Store, removals []store.Removal, dryRun bool) {
+	if len(removals) == 0 {
+		fmt.Println("nothing to remove")
+		return
+	}
+
+	table := NewTable()
+	table.AddRow("ACCOUNT ID", "AWS PROFILE", "ALIAS", "REGION", "IMPORTED", "AGE", "SNAPSHOTS")
+	for _, v := range removals {
+		region, imported, age, snapshots := "all", "-", "-", "all"
+		if v.Region != "" {
+			region = v.Region
+		}
+		if !v.ImportedAt.IsZero() {
+			imported = v.ImportedAt.Local().Format(time.DateTime)
+			age = FormatAge(time.Since(v.ImportedAt))
+		}
+		if len(v.Snapshots) > 0 {
+			snapshots = strconv.Itoa(len(v.Snapshots))
+		}
+		table.AddRow(v.Account.Id, v.Account.Profile, v.Account.Alias, region, imported, age, snapshots)
+	}
+	table.Print()
+
+	if dryRun {
+		fmt.Printf("\ndry run, %d accounts or regions would be removed\n", len(removals))
+		return
+	}
+	removed, err := store.Remove(dataStore, removals)
+	fmt.Printf("\nremoved %d of %d accounts or regions\n", removed, len(removals))
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
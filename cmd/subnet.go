@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/index"
 	"github.com/pete911/awf/internal/out"
+	"github.com/pete911/awf/internal/store"
 	"github.com/pete911/awf/internal/types"
 	"github.com/spf13/cobra"
 	"os"
@@ -19,6 +22,7 @@ var (
 
 func init() {
 	Root.AddCommand(subnetCmd)
+	flag.InitSearchFlags(subnetCmd, &searchFlags)
 }
 
 func runSubnet(cmd *cobra.Command, args []string) {
@@ -27,26 +31,22 @@ func runSubnet(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fileStore := LoadFileStore()
-	accounts, err := fileStore.ListAccounts()
+	dataStore, unlock := LoadSearchStore()
+	defer unlock()
+	accounts, err := dataStore.ListAccounts()
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
-	vpcs, err := fileStore.DescribeVpcs()
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
-	}
-
-	subnets, err := fileStore.DescribeSubnets()
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	// subnets are read once for all id arguments
+	var ids []string
+	for _, arg := range args {
+		if IsSubnetId(arg) {
+			ids = append(ids, arg)
+		}
 	}
-
-	nis, err := fileStore.DescribeNetworkInterfaces()
+	byId, err := dataStore.GetSubnetsById(ids...)
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
@@ -54,17 +54,41 @@ func runSubnet(cmd *cobra.Command, args []string) {
 
 	var matched types.Subnets
 	for _, arg := range args {
-		if found := findSubnets(arg, subnets); len(found) > 0 {
-			matched = append(matched, found...)
+		found, err := findSubnets(arg, byId, dataStore)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		matched = append(matched, found...)
 	}
 
 	if len(matched) == 0 {
-		fmt.Printf("searched %d vpcs, but none matched\n", len(vpcs))
+		counts, err := store.CountItems(dataStore)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("searched %d subnets, but none matched\n", counts.Subnets)
+		PrintWarnings(dataStore)
 		return
 	}
 
-	printSubnets(nis, vpcs, matched, accounts)
+	var regions []AccountRegion
+	for _, v := range matched {
+		regions = append(regions, AccountRegion{Account: v.Account, Region: v.Region})
+	}
+	stale := CheckStale(dataStore, regions)
+
+	related, err := dataStore.Related(nil, matched)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	printSubnets(related.NetworkInterfaces, related.Vpcs, matched, accounts)
+	PrintStale(stale)
+	PrintSnapshots(dataStore, regions)
+	PrintWarnings(dataStore)
 }
 
 func printSubnets(nis types.NetworkInterfaces, vpcs types.Vpcs, subnets types.Subnets, accounts types.Accounts) {
@@ -95,17 +119,23 @@ func printSubnets(nis types.NetworkInterfaces, vpcs types.Vpcs, subnets types.Su
 	table.Print()
 }
 
-func findSubnets(arg string, subnets types.Subnets) types.Subnets {
-	if IsIP(arg) {
-		return subnets.GetByIp(arg)
-	}
+// findSubnets finds subnets by id, or by IP and CIDR using store lookup, that uses store prefix index
+func findSubnets(arg string, byId types.Subnets, dataStore store.Store) (types.Subnets, error) {
 	if IsSubnetId(arg) {
-		return subnets.GetById(arg)
+		return byId.GetById(arg), nil
 	}
-	if IsCIDR(arg) {
-		return subnets.GetByCidr(arg)
+	if IsIP(arg) || IsCIDR(arg) {
+		prefix, err := index.ParsePrefix(arg)
+		if err != nil {
+			return nil, err
+		}
+		result, err := dataStore.Lookup(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return result.Subnets, nil
 	}
-	fmt.Printf("argument %s can only be IP, CIDR, or network interface id", arg)
+	fmt.Printf("argument %s can only be IP, CIDR, or subnet id", arg)
 	os.Exit(1)
-	return nil
+	return nil, nil
 }
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/pete911/awf/cmd/flag"
+	"github.com/pete911/awf/internal/index"
 	"github.com/pete911/awf/internal/out"
+	"github.com/pete911/awf/internal/store"
 	"github.com/pete911/awf/internal/types"
 	"github.com/spf13/cobra"
 	"os"
@@ -19,6 +22,7 @@ var (
 
 func init() {
 	Root.AddCommand(vpcCmd)
+	flag.InitSearchFlags(vpcCmd, &searchFlags)
 }
 
 func runVpc(cmd *cobra.Command, args []string) {
@@ -27,26 +31,22 @@ func runVpc(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fileStore := LoadFileStore()
-	accounts, err := fileStore.ListAccounts()
+	dataStore, unlock := LoadSearchStore()
+	defer unlock()
+	accounts, err := dataStore.ListAccounts()
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
-	vpcs, err := fileStore.DescribeVpcs()
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
-	}
-
-	sunbets, err := fileStore.DescribeSubnets()
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	// vpcs are read once for all id arguments
+	var ids []string
+	for _, arg := range args {
+		if IsVpcId(arg) {
+			ids = append(ids, arg)
+		}
 	}
-
-	nis, err := fileStore.DescribeNetworkInterfaces()
+	byId, err := dataStore.GetVpcsById(ids...)
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
@@ -54,17 +54,41 @@ func runVpc(cmd *cobra.Command, args []string) {
 
 	var matched types.Vpcs
 	for _, arg := range args {
-		if found := findVpcs(arg, vpcs); len(found) > 0 {
-			matched = append(matched, found...)
+		found, err := findVpcs(arg, byId, dataStore)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
+		matched = append(matched, found...)
 	}
 
 	if len(matched) == 0 {
-		fmt.Printf("searched %d vpcs, but none matched\n", len(vpcs))
+		counts, err := store.CountItems(dataStore)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("searched %d vpcs, but none matched\n", counts.Vpcs)
+		PrintWarnings(dataStore)
 		return
 	}
 
-	printVpcs(nis, matched, sunbets, accounts)
+	var regions []AccountRegion
+	for _, v := range matched {
+		regions = append(regions, AccountRegion{Account: v.Account, Region: v.Region})
+	}
+	stale := CheckStale(dataStore, regions)
+
+	related, err := dataStore.Related(matched, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	printVpcs(related.NetworkInterfaces, matched, related.Subnets, accounts)
+	PrintStale(stale)
+	PrintSnapshots(dataStore, regions)
+	PrintWarnings(dataStore)
 }
 
 // nis types.NetworkInterfaces, vpcs types.Vpcs, subnets types.Subnets
@@ -92,17 +116,23 @@ func printVpcs(nis types.NetworkInterfaces, vpcs types.Vpcs, subnets types.Subne
 	table.Print()
 }
 
-func findVpcs(arg string, vpcs types.Vpcs) types.Vpcs {
-	if IsIP(arg) {
-		return vpcs.GetByIp(arg)
-	}
+// findVpcs finds vpcs by id, or by IP and CIDR using store lookup, that uses store prefix index
+func findVpcs(arg string, byId types.Vpcs, dataStore store.Store) (types.Vpcs, error) {
 	if IsVpcId(arg) {
-		return vpcs.GetById(arg)
+		return byId.GetById(arg), nil
 	}
-	if IsCIDR(arg) {
-		return vpcs.GetByCidr(arg)
+	if IsIP(arg) || IsCIDR(arg) {
+		prefix, err := index.ParsePrefix(arg)
+		if err != nil {
+			return nil, err
+		}
+		result, err := dataStore.Lookup(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return result.Vpcs, nil
 	}
-	fmt.Printf("argument %s can only be IP, CIDR, or network interface id", arg)
+	fmt.Printf("argument %s can only be IP, CIDR, or vpc id", arg)
 	os.Exit(1)
-	return nil
+	return nil, nil
 }
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/internal/store"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+)
+
+var (
+	workspaceCmd = &cobra.Command{
+		Use:   "workspace",
+		Short: "manage workspaces, separate stores of imported data",
+		Long: `workspace is a separate store of imported data (e.g. per organization or environment) in the awf directory,
+select it by --workspace flag. Data imported without --workspace flag are in default workspace.`,
+	}
+	workspaceListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list workspaces and number of imported accounts",
+		Args:  cobra.NoArgs,
+		Run:   runWorkspaceList,
+	}
+	workspaceCreateCmd = &cobra.Command{
+		Use:   "create <name>",
+		Short: "create workspace",
+		Args:  cobra.ExactArgs(1),
+		Run:   runWorkspaceCreate,
+	}
+	workspaceDeleteCmd = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete workspace and all data imported to it",
+		Args:  cobra.ExactArgs(1),
+		Run:   runWorkspaceDelete,
+	}
+)
+
+func init() {
+	Root.AddCommand(workspaceCmd)
+	workspaceCmd.AddCommand(workspaceListCmd)
+	workspaceCmd.AddCommand(workspaceCreateCmd)
+	workspaceCmd.AddCommand(workspaceDeleteCmd)
+}
+
+func runWorkspaceList(_ *cobra.Command, _ []string) {
+	dir := StoreDir()
+	workspaces, err := store.ListWorkspaces(dir)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	cfg := LoadConfig()
+	table := NewTable()
+	table.AddRow("WORKSPACE", "DIR", "ACCOUNTS")
+	for _, workspace := range workspaces {
+		dataStore, err := store.Load(store.Options{
+			Backend:    cfg.Store,
+			Dir:        dir,
+			Workspaces: []string{workspace},
+			Encoding:   StoreEncoding(cfg),
+		})
+		// workspace that cannot be loaded (e.g. passphrase store key does not exist yet) is listed without accounts
+		accounts := "-"
+		if err == nil {
+			accounts = countAccounts(dataStore)
+		}
+		table.AddRow(workspace, store.WorkspaceDir(dir, workspace), accounts)
+	}
+	table.Print()
+}
+
+// countAccounts returns number of accounts in the store, or "-" if the store cannot be read
+func countAccounts(s store.Store) string {
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		var notFound *store.NotFoundError
+		if errors.As(err, &notFound) {
+			return "0"
+		}
+		return "-"
+	}
+	return strconv.Itoa(len(accounts))
+}
+
+func runWorkspaceCreate(_ *cobra.Command, args []string) {
+	if err := store.CreateWorkspace(StoreDir(), args[0]); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("created workspace %s, import to it with 'awf import --workspace %s'\n", args[0], args[0])
+}
+
+func runWorkspaceDelete(_ *cobra.Command, args []string) {
+	if err := store.DeleteWorkspace(StoreDir(), args[0], GlobalFlags.Wait); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("deleted workspace %s\n", args[0])
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const configFile = "config.json"
+
+// Config is optional awf configuration, loaded from config.json file in awf directory. Flags take precedence over
+// configuration.
+type Config struct {
+	// MaxAge is the age (e.g. 12h or 7d) after which imported data are reported as stale
+	MaxAge string `json:"max_age"`
+	// Store is the store backend, default is 'file' (json files in awf directory)
+	Store string `json:"store"`
+	// Compression of file store files, zstd (default), gzip or none
+	Compression string `json:"compression"`
+	// KeyFile is age identity file that encrypts file store, AWF_KEY_FILE env. variable takes precedence
+	KeyFile string `json:"key_file"`
+	// ProfilePriority are glob patterns (e.g. '*-readonly') that select preferred profile of accounts imported by more
+	// than one profile, the first matching pattern wins
+	ProfilePriority []string `json:"profile_priority"`
+}
+
+// Path returns path of the config file in supplied directory
+func Path(dir string) string {
+	return filepath.Join(dir, configFile)
+}
+
+// Load loads configuration from supplied directory, missing config file is not an error
+func Load(dir string) (Config, error) {
+	path := Path(dir)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Config{}, nil
+		}
+		return Config{}, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("unmarshal %s: %w", path, err)
+	}
+	return cfg, nil
+}
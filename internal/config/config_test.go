@@ -0,0 +1,21 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, Config{}, cfg)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, configFile), []byte(`{"max_age": "3d"}`), 0600))
+	cfg, err = Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "3d", cfg.MaxAge)
+}
@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"reflect"
+	"slices"
+	"time"
+)
+
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+
+	VpcResource              = "vpc"
+	SubnetResource           = "subnet"
+	NetworkInterfaceResource = "network-interface"
+)
+
+type Changes []Change
+
+// Change is added, removed or changed resource between two snapshots
+type Change struct {
+	AccountId string  `json:"account_id"`
+	Region    string  `json:"region"`
+	Resource  string  `json:"resource"`
+	Id        string  `json:"id"`
+	Name      string  `json:"name,omitempty"`
+	Type      string  `json:"type"`
+	Fields    []Field `json:"fields,omitempty"`
+}
+
+// Field is changed field of the resource
+type Field struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff returns changes of VPCs, subnets and network interfaces between from and to, sorted by account, region,
+// resource and id
+func Diff(fromVpcs, toVpcs types.Vpcs, fromSubnets, toSubnets types.Subnets, fromNis, toNis types.NetworkInterfaces) Changes {
+	changes := slices.Concat(Vpcs(fromVpcs, toVpcs), Subnets(fromSubnets, toSubnets), NetworkInterfaces(fromNis, toNis))
+	slices.SortFunc(changes, func(a, b Change) int {
+		return cmp.Or(
+			cmp.Compare(a.AccountId, b.AccountId),
+			cmp.Compare(a.Region, b.Region),
+			cmp.Compare(a.Resource, b.Resource),
+			cmp.Compare(a.Id, b.Id),
+		)
+	})
+	return changes
+}
+
+func Vpcs(from, to types.Vpcs) Changes {
+	return diff(VpcResource, from, to, func(v types.Vpc) (key, string) {
+		return key{accountId: v.Account.Id, region: v.Region, id: v.VpcId}, v.Name
+	})
+}
+
+func Subnets(from, to types.Subnets) Changes {
+	return diff(SubnetResource, from, to, func(v types.Subnet) (key, string) {
+		return key{accountId: v.Account.Id, region: v.Region, id: v.SubnetId}, v.Name
+	})
+}
+
+func NetworkInterfaces(from, to types.NetworkInterfaces) Changes {
+	return diff(NetworkInterfaceResource, from, to, func(v types.NetworkInterface) (key, string) {
+		return key{accountId: v.Account.Id, region: v.Region, id: v.NetworkInterfaceId}, v.Description
+	})
+}
+
+// key identifies resource across snapshots
+type key struct {
+	accountId string
+	region    string
+	id        string
+}
+
+func (k key) change(resource, name, changeType string, fields []Field) Change {
+	return Change{
+		AccountId: k.accountId,
+		Region:    k.region,
+		Resource:  resource,
+		Id:        k.id,
+		Name:      name,
+		Type:      changeType,
+		Fields:    fields,
+	}
+}
+
+// diff compares resources by key, keyFn returns key and name (description) of the resource
+func diff[T any](resource string, from, to []T, keyFn func(T) (key, string)) Changes {
+	fromByKey := make(map[key]T)
+	for _, v := range from {
+		k, _ := keyFn(v)
+		fromByKey[k] = v
+	}
+
+	var out Changes
+	seen := make(map[key]bool)
+	for _, v := range to {
+		k, name := keyFn(v)
+		seen[k] = true
+		prev, ok := fromByKey[k]
+		if !ok {
+			out = append(out, k.change(resource, name, Added, nil))
+			continue
+		}
+		if fields := changedFields(prev, v); len(fields) > 0 {
+			out = append(out, k.change(resource, name, Changed, fields))
+		}
+	}
+	for _, v := range from {
+		if k, name := keyFn(v); !seen[k] {
+			out = append(out, k.change(resource, name, Removed, nil))
+		}
+	}
+	return out
+}
+
+// changedFields compares exported fields of the resource, account (e.g. alias) and region are part of the resource key
+func changedFields[T any](from, to T) []Field {
+	fromValue, toValue := reflect.ValueOf(from), reflect.ValueOf(to)
+	var out []Field
+	for i := range fromValue.NumField() {
+		name := fromValue.Type().Field(i).Name
+		if name == "Account" || name == "Region" {
+			continue
+		}
+		a, b := fromValue.Field(i).Interface(), toValue.Field(i).Interface()
+		if !reflect.DeepEqual(a, b) {
+			out = append(out, Field{Name: name, From: format(a), To: format(b)})
+		}
+	}
+	return out
+}
+
+func format(v any) string {
+	if t, ok := v.(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,28 @@
+package diff
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	from := types.Vpcs{
+		{Account: account, Region: "eu-west-2", VpcId: "vpc-1", Name: "one", State: "pending"},
+		{Account: account, Region: "eu-west-2", VpcId: "vpc-2", Name: "two", State: "available"},
+	}
+	to := types.Vpcs{
+		{Account: types.Account{Id: "123456789012", Alias: "new-alias"}, Region: "eu-west-2", VpcId: "vpc-1", Name: "one", State: "available"},
+		{Account: account, Region: "eu-west-1", VpcId: "vpc-2", Name: "two", State: "available"},
+	}
+	subnets := types.Subnets{{Account: account, Region: "eu-west-2", SubnetId: "subnet-1"}}
+
+	changes := Diff(from, to, subnets, subnets, nil, types.NetworkInterfaces{{Account: account, Region: "eu-west-2", NetworkInterfaceId: "eni-1", Description: "lambda"}})
+	assert.Equal(t, Changes{
+		{AccountId: "123456789012", Region: "eu-west-1", Resource: VpcResource, Id: "vpc-2", Name: "two", Type: Added},
+		{AccountId: "123456789012", Region: "eu-west-2", Resource: NetworkInterfaceResource, Id: "eni-1", Name: "lambda", Type: Added},
+		{AccountId: "123456789012", Region: "eu-west-2", Resource: VpcResource, Id: "vpc-1", Name: "one", Type: Changed, Fields: []Field{{Name: "State", From: "pending", To: "available"}}},
+		{AccountId: "123456789012", Region: "eu-west-2", Resource: VpcResource, Id: "vpc-2", Name: "two", Type: Removed},
+	}, changes)
+}
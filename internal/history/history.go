@@ -0,0 +1,75 @@
+package history
+
+import (
+	"cmp"
+	"github.com/pete911/awf/internal/types"
+	"slices"
+	"time"
+)
+
+// Entry is a period in which network interface held the IP
+type Entry struct {
+	NetworkInterface types.NetworkInterface
+	FirstSeen        time.Time
+	LastSeen         time.Time
+	// Current is set if the network interface holds the IP in the latest import
+	Current bool
+}
+
+type key struct {
+	accountId          string
+	region             string
+	networkInterfaceId string
+}
+
+// Timeline builds IP ownership timeline from snapshots. Snapshots of a region have to be added oldest first.
+type Timeline struct {
+	ip      string
+	open    map[key]*Entry
+	entries []Entry
+}
+
+func NewTimeline(ip string) *Timeline {
+	return &Timeline{ip: ip, open: make(map[key]*Entry)}
+}
+
+// Add adds snapshot of the region. Network interface that held the IP in the previous snapshot, but not in this one,
+// closes its period. If it holds the IP again later, new period is started.
+func (t *Timeline) Add(accountId, region string, snapshot time.Time, nis types.NetworkInterfaces) {
+	seen := make(map[key]bool)
+	for _, ni := range nis.GetByIp(t.ip) {
+		k := key{accountId: accountId, region: region, networkInterfaceId: ni.NetworkInterfaceId}
+		seen[k] = true
+		if e, ok := t.open[k]; ok {
+			e.NetworkInterface = ni
+			e.LastSeen = snapshot
+			continue
+		}
+		t.open[k] = &Entry{NetworkInterface: ni, FirstSeen: snapshot, LastSeen: snapshot}
+	}
+
+	for k, e := range t.open {
+		if k.accountId == accountId && k.region == region && !seen[k] {
+			t.entries = append(t.entries, *e)
+			delete(t.open, k)
+		}
+	}
+}
+
+// Entries returns timeline entries sorted by the first time they were seen. Entries that are still open (seen in
+// the latest snapshot of the region) are current.
+func (t *Timeline) Entries() []Entry {
+	out := slices.Clone(t.entries)
+	for _, e := range t.open {
+		current := *e
+		current.Current = true
+		out = append(out, current)
+	}
+	slices.SortFunc(out, func(a, b Entry) int {
+		return cmp.Or(
+			a.FirstSeen.Compare(b.FirstSeen),
+			cmp.Compare(a.NetworkInterface.NetworkInterfaceId, b.NetworkInterface.NetworkInterfaceId),
+		)
+	})
+	return out
+}
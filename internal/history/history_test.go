@@ -0,0 +1,36 @@
+package history
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 10, n, 0, 0, 0, 0, time.UTC) }
+	eni := func(id, ip string) types.NetworkInterface {
+		return types.NetworkInterface{NetworkInterfaceId: id, PrivateIpAddress: ip, PrivateIpAddresses: []string{ip}}
+	}
+
+	timeline := NewTimeline("10.0.0.1")
+	timeline.Add("123456789012", "eu-west-2", day(1), types.NetworkInterfaces{eni("eni-1", "10.0.0.1"), eni("eni-2", "10.0.0.2")})
+	timeline.Add("123456789012", "eu-west-2", day(2), types.NetworkInterfaces{eni("eni-1", "10.0.0.1")})
+	timeline.Add("123456789012", "eu-west-2", day(3), types.NetworkInterfaces{eni("eni-3", "10.0.0.1")})
+	// other region does not close eni-3 period
+	timeline.Add("123456789012", "eu-west-1", day(4), types.NetworkInterfaces{})
+	timeline.Add("123456789012", "eu-west-2", day(5), types.NetworkInterfaces{eni("eni-3", "10.0.0.1")})
+
+	entries := timeline.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "eni-1", entries[0].NetworkInterface.NetworkInterfaceId)
+	assert.Equal(t, day(1), entries[0].FirstSeen)
+	assert.Equal(t, day(2), entries[0].LastSeen)
+	assert.False(t, entries[0].Current)
+
+	assert.Equal(t, "eni-3", entries[1].NetworkInterface.NetworkInterfaceId)
+	assert.Equal(t, day(3), entries[1].FirstSeen)
+	assert.Equal(t, day(5), entries[1].LastSeen)
+	assert.True(t, entries[1].Current)
+}
@@ -0,0 +1,255 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/netip"
+	"slices"
+)
+
+// format version of the binary encoding, see MarshalBinary
+const formatVersion = 1
+
+// Trie is path compressed binary (radix) trie of IP prefixes, every prefix maps to values (resource ids). IPs are
+// inserted as host prefixes (/32 or /128). IPv4 and IPv6 prefixes are in separate trees.
+type Trie struct {
+	v4  *node
+	v6  *node
+	len int
+}
+
+type node struct {
+	prefix   netip.Prefix
+	values   []string
+	children [2]*node
+}
+
+func New() *Trie {
+	return &Trie{}
+}
+
+// Len returns number of inserted values
+func (t *Trie) Len() int {
+	return t.len
+}
+
+// Insert inserts value under prefix, prefix is masked (10.0.0.1/16 is inserted as 10.0.0.0/16)
+func (t *Trie) Insert(prefix netip.Prefix, value string) {
+	if !prefix.IsValid() {
+		return
+	}
+	prefix = prefix.Masked()
+	n := t.root(prefix.Addr())
+	for {
+		cur := *n
+		if cur == nil {
+			*n = &node{prefix: prefix, values: []string{value}}
+			t.len++
+			return
+		}
+
+		common := commonPrefix(cur.prefix, prefix)
+		if common.Bits() < cur.prefix.Bits() {
+			parent := &node{prefix: common}
+			parent.children[bitAt(cur.prefix.Addr(), common.Bits())] = cur
+			*n = parent
+			cur = parent
+		}
+		if cur.prefix.Bits() == prefix.Bits() {
+			cur.values = append(cur.values, value)
+			t.len++
+			return
+		}
+		n = &cur.children[bitAt(prefix.Addr(), cur.prefix.Bits())]
+	}
+}
+
+// InsertString inserts value under IP or CIDR
+func (t *Trie) InsertString(in, value string) error {
+	prefix, err := ParsePrefix(in)
+	if err != nil {
+		return err
+	}
+	t.Insert(prefix, value)
+	return nil
+}
+
+// Overlapping returns unique values of prefixes that overlap supplied prefix. These are prefixes that contain the
+// prefix (e.g. VPC CIDR that contains searched IP) and prefixes that are contained by the prefix (e.g. IPs in
+// searched CIDR).
+func (t *Trie) Overlapping(prefix netip.Prefix) []string {
+	if !prefix.IsValid() {
+		return nil
+	}
+	prefix = prefix.Masked()
+
+	var out []string
+	n := *t.root(prefix.Addr())
+	for n != nil && n.prefix.Overlaps(prefix) {
+		if n.prefix.Bits() >= prefix.Bits() {
+			out = n.collect(out)
+			break
+		}
+		out = append(out, n.values...)
+		n = n.children[bitAt(prefix.Addr(), n.prefix.Bits())]
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// collect appends values of the node and all its descendants
+func (n *node) collect(out []string) []string {
+	out = append(out, n.values...)
+	for _, child := range n.children {
+		if child != nil {
+			out = child.collect(out)
+		}
+	}
+	return out
+}
+
+func (t *Trie) root(addr netip.Addr) **node {
+	if addr.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+// walk calls fn for every prefix and value
+func (t *Trie) walk(fn func(prefix netip.Prefix, value string) error) error {
+	var walkNode func(n *node) error
+	walkNode = func(n *node) error {
+		if n == nil {
+			return nil
+		}
+		for _, v := range n.values {
+			if err := fn(n.prefix, v); err != nil {
+				return err
+			}
+		}
+		if err := walkNode(n.children[0]); err != nil {
+			return err
+		}
+		return walkNode(n.children[1])
+	}
+	if err := walkNode(t.v4); err != nil {
+		return err
+	}
+	return walkNode(t.v6)
+}
+
+// MarshalBinary encodes the trie as version byte followed by entries. Entry is address length (4 or 16), address,
+// prefix bits, value length (uvarint) and value.
+func (t *Trie) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(formatVersion)
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	err := t.walk(func(prefix netip.Prefix, value string) error {
+		addr := prefix.Addr().AsSlice()
+		buf.WriteByte(byte(len(addr)))
+		buf.Write(addr)
+		buf.WriteByte(byte(prefix.Bits()))
+		buf.Write(lenBuf[:binary.PutUvarint(lenBuf, uint64(len(value)))])
+		buf.WriteString(value)
+		return nil
+	})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary decodes trie encoded by MarshalBinary
+func (t *Trie) UnmarshalBinary(data []byte) error {
+	r := bufio.NewReader(bytes.NewReader(data))
+	version, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read index version: %w", err)
+	}
+	if version != formatVersion {
+		return fmt.Errorf("unsupported index version %d", version)
+	}
+
+	*t = Trie{}
+	addr := make([]byte, 16)
+	for {
+		addrLen, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if addrLen != 4 && addrLen != 16 {
+			return fmt.Errorf("invalid index address length %d", addrLen)
+		}
+		if _, err := io.ReadFull(r, addr[:addrLen]); err != nil {
+			return fmt.Errorf("read index address: %w", err)
+		}
+		prefixBits, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("read index prefix: %w", err)
+		}
+		valueLen, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("read index value: %w", err)
+		}
+		value := make([]byte, valueLen)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return fmt.Errorf("read index value: %w", err)
+		}
+
+		ip, _ := netip.AddrFromSlice(addr[:addrLen])
+		prefix := netip.PrefixFrom(ip, int(prefixBits))
+		if !prefix.IsValid() {
+			return fmt.Errorf("invalid index prefix %s/%d", ip, prefixBits)
+		}
+		t.Insert(prefix, string(value))
+	}
+}
+
+// ParsePrefix parses CIDR, or IP as host prefix (/32 or /128)
+func ParsePrefix(in string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(in); err == nil {
+		return prefix, nil
+	}
+	addr, err := netip.ParseAddr(in)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%s is not IP or CIDR", in)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// commonPrefix returns the longest prefix that contains both prefixes
+func commonPrefix(a, b netip.Prefix) netip.Prefix {
+	maxBits := min(a.Bits(), b.Bits())
+	aBytes, bBytes := a.Addr().As16(), b.Addr().As16()
+	offset := addrOffset(a.Addr())
+	n := 0
+	for i := offset / 8; i < len(aBytes) && n < maxBits; i++ {
+		if x := aBytes[i] ^ bBytes[i]; x != 0 {
+			n += bits.LeadingZeros8(x)
+			break
+		}
+		n += 8
+	}
+	p, _ := a.Addr().Prefix(min(n, maxBits))
+	return p
+}
+
+// bitAt returns bit of the address at position i (0 is the most significant bit)
+func bitAt(addr netip.Addr, i int) int {
+	b := addr.As16()
+	i += addrOffset(addr)
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// addrOffset returns bit offset of the address in 16 byte representation, IPv4 addresses are IPv4-mapped IPv6
+func addrOffset(addr netip.Addr) int {
+	if addr.Is4() {
+		return 96
+	}
+	return 0
+}
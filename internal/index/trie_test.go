@@ -0,0 +1,130 @@
+package index
+
+import (
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+func TestTrie_Overlapping(t *testing.T) {
+	trie := New()
+	for _, v := range []struct{ prefix, value string }{
+		{"10.0.0.0/16", "vpc-1"},
+		{"10.0.1.0/24", "subnet-1"},
+		{"10.0.2.0/24", "subnet-2"},
+		{"10.0.1.10", "eni-1"},
+		{"10.0.1.11", "eni-2"},
+		{"10.0.2.10", "eni-3"},
+		{"10.0.2.10", "eni-4"},
+		{"172.16.0.0/12", "vpc-2"},
+		{"2001:db8::/56", "subnet-3"},
+		{"2001:db8::10", "eni-5"},
+	} {
+		require.NoError(t, trie.InsertString(v.prefix, v.value))
+	}
+	assert.Equal(t, 10, trie.Len())
+
+	tests := []struct {
+		in       string
+		expected []string
+	}{
+		{in: "10.0.1.10", expected: []string{"eni-1", "subnet-1", "vpc-1"}},
+		{in: "10.0.2.10", expected: []string{"eni-3", "eni-4", "subnet-2", "vpc-1"}},
+		{in: "10.0.3.1", expected: []string{"vpc-1"}},
+		{in: "10.0.1.0/25", expected: []string{"eni-1", "eni-2", "subnet-1", "vpc-1"}},
+		{in: "10.0.0.0/8", expected: []string{"eni-1", "eni-2", "eni-3", "eni-4", "subnet-1", "subnet-2", "vpc-1"}},
+		{in: "172.31.0.1", expected: []string{"vpc-2"}},
+		{in: "192.168.0.1"},
+		{in: "2001:db8::10", expected: []string{"eni-5", "subnet-3"}},
+		{in: "::ffff:10.0.1.10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			prefix, err := ParsePrefix(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, trie.Overlapping(prefix))
+		})
+	}
+
+	b, err := trie.MarshalBinary()
+	require.NoError(t, err)
+	decoded := New()
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	assert.Equal(t, trie.Len(), decoded.Len())
+	assert.Equal(t, trie.Overlapping(netip.MustParsePrefix("0.0.0.0/0")), decoded.Overlapping(netip.MustParsePrefix("0.0.0.0/0")))
+	assert.Equal(t, []string{"eni-5", "subnet-3"}, decoded.Overlapping(netip.MustParsePrefix("2001:db8::/32")))
+}
+
+const benchmarkSize = 1_000_000
+
+var (
+	benchmarkOnce sync.Once
+	benchmarkNis  types.NetworkInterfaces
+	benchmarkTrie *Trie
+)
+
+// benchmarkData returns 1M synthetic network interfaces (10.0.0.0/8) and their index
+func benchmarkData() (types.NetworkInterfaces, *Trie) {
+	benchmarkOnce.Do(func() {
+		benchmarkTrie = New()
+		for i := range benchmarkSize {
+			ip := netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}).String()
+			id := fmt.Sprintf("eni-%08x", i)
+			benchmarkNis = append(benchmarkNis, types.NetworkInterface{
+				NetworkInterfaceId: id,
+				PrivateIpAddress:   ip,
+				PrivateIpAddresses: []string{ip},
+			})
+			benchmarkTrie.Insert(netip.PrefixFrom(netip.MustParseAddr(ip), 32), id)
+		}
+	})
+	return benchmarkNis, benchmarkTrie
+}
+
+func BenchmarkNetworkInterfaces_GetByIp(b *testing.B) {
+	nis, _ := benchmarkData()
+	b.ResetTimer()
+	for range b.N {
+		nis.GetByIp("10.8.1.2")
+	}
+}
+
+func BenchmarkTrie_Overlapping_ip(b *testing.B) {
+	_, trie := benchmarkData()
+	prefix := netip.MustParsePrefix("10.8.1.2/32")
+	b.ResetTimer()
+	for range b.N {
+		trie.Overlapping(prefix)
+	}
+}
+
+func BenchmarkNetworkInterfaces_GetByCidr(b *testing.B) {
+	nis, _ := benchmarkData()
+	b.ResetTimer()
+	for range b.N {
+		nis.GetByCidr("10.8.1.0/24")
+	}
+}
+
+func BenchmarkTrie_Overlapping_cidr(b *testing.B) {
+	_, trie := benchmarkData()
+	prefix := netip.MustParsePrefix("10.8.1.0/24")
+	b.ResetTimer()
+	for range b.N {
+		trie.Overlapping(prefix)
+	}
+}
+
+func BenchmarkTrie_UnmarshalBinary(b *testing.B) {
+	_, trie := benchmarkData()
+	data, err := trie.MarshalBinary()
+	require.NoError(b, err)
+	b.ResetTimer()
+	for range b.N {
+		require.NoError(b, New().UnmarshalBinary(data))
+	}
+}
@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	version = "2012-10-17"
+	sid     = "AwfImport"
+	// Name is name of the policy (and its terraform and cloudformation resources)
+	Name = "awf-import"
+)
+
+// Policy is IAM policy document that allows supplied actions on all resources
+type Policy struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+type Statement struct {
+	Sid      string   `json:"Sid"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource string   `json:"Resource"`
+}
+
+func New(actions []string) Policy {
+	return Policy{
+		Version:   version,
+		Statement: []Statement{{Sid: sid, Effect: "Allow", Action: actions, Resource: "*"}},
+	}
+}
+
+// Json returns indented json policy document
+func (p Policy) Json() (string, error) {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Terraform returns aws_iam_policy_document data source and aws_iam_policy resource that uses it
+func (p Policy) Terraform() string {
+	name := strings.ReplaceAll(Name, "-", "_")
+	var b strings.Builder
+	fmt.Fprintf(&b, "data \"aws_iam_policy_document\" %q {\n", name)
+	for _, s := range p.Statement {
+		b.WriteString("  statement {\n")
+		fmt.Fprintf(&b, "    sid     = %q\n", s.Sid)
+		fmt.Fprintf(&b, "    effect  = %q\n", s.Effect)
+		b.WriteString("    actions = [\n")
+		for _, action := range s.Action {
+			fmt.Fprintf(&b, "      %q,\n", action)
+		}
+		b.WriteString("    ]\n")
+		fmt.Fprintf(&b, "    resources = [%q]\n", s.Resource)
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "resource \"aws_iam_policy\" %q {\n", name)
+	fmt.Fprintf(&b, "  name   = %q\n", Name)
+	fmt.Fprintf(&b, "  policy = data.aws_iam_policy_document.%s.json\n", name)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// CloudFormation returns yaml template with AWS::IAM::ManagedPolicy resource
+func (p Policy) CloudFormation() string {
+	var b strings.Builder
+	b.WriteString("Resources:\n")
+	b.WriteString("  AwfImportPolicy:\n")
+	b.WriteString("    Type: AWS::IAM::ManagedPolicy\n")
+	b.WriteString("    Properties:\n")
+	fmt.Fprintf(&b, "      ManagedPolicyName: %s\n", Name)
+	b.WriteString("      PolicyDocument:\n")
+	fmt.Fprintf(&b, "        Version: %q\n", p.Version)
+	b.WriteString("        Statement:\n")
+	for _, s := range p.Statement {
+		fmt.Fprintf(&b, "          - Sid: %s\n", s.Sid)
+		fmt.Fprintf(&b, "            Effect: %s\n", s.Effect)
+		b.WriteString("            Action:\n")
+		for _, action := range s.Action {
+			fmt.Fprintf(&b, "              - %s\n", action)
+		}
+		fmt.Fprintf(&b, "            Resource: %q\n", s.Resource)
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	p := New([]string{"ec2:DescribeVpcs", "sts:GetCallerIdentity"})
+
+	out, err := p.Json()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Sid": "AwfImport",
+			"Effect": "Allow",
+			"Action": ["ec2:DescribeVpcs", "sts:GetCallerIdentity"],
+			"Resource": "*"
+		}]
+	}`, out)
+
+	assert.Equal(t, `data "aws_iam_policy_document" "awf_import" {
+  statement {
+    sid     = "AwfImport"
+    effect  = "Allow"
+    actions = [
+      "ec2:DescribeVpcs",
+      "sts:GetCallerIdentity",
+    ]
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_policy" "awf_import" {
+  name   = "awf-import"
+  policy = data.aws_iam_policy_document.awf_import.json
+}
+`, p.Terraform())
+
+	assert.Equal(t, `Resources:
+  AwfImportPolicy:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      ManagedPolicyName: awf-import
+      PolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Sid: AwfImport
+            Effect: Allow
+            Action:
+              - ec2:DescribeVpcs
+              - sts:GetCallerIdentity
+            Resource: "*"
+`, p.CloudFormation())
+}
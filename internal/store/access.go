@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"slices"
+	"sync"
+	"time"
+)
+
+// apiCall is aws api call made by import. Action is IAM action that allows the call, check makes the call with
+// minimal result to find out if the credentials are allowed to make it.
+type apiCall struct {
+	action string
+	check  func(cfg aws.Config, timeout time.Duration) error
+}
+
+// accountCalls are made by import of every account, see getCurrentAWSAccount
+var accountCalls = []apiCall{
+	{action: "sts:GetCallerIdentity", check: checkGetCallerIdentity},
+	{action: "iam:ListAccountAliases", check: checkListAccountAliases},
+}
+
+// regionsCall is made only by import of all regions, see describeRegions
+var regionsCall = apiCall{action: "ec2:DescribeRegions", check: checkDescribeRegions}
+
+// importCalls returns aws api calls made by import of the account, account calls first and then calls of registered
+// importers. If allRegions is set, call that resolves all regions is included as well.
+func importCalls(allRegions bool) []apiCall {
+	calls := slices.Clone(accountCalls)
+	if allRegions {
+		calls = append(calls, regionsCall)
+	}
+	for _, i := range importers {
+		calls = append(calls, i.calls...)
+	}
+	return calls
+}
+
+// ImportActions returns sorted IAM actions that import of the account needs, see importCalls
+func ImportActions(allRegions bool) []string {
+	var actions []string
+	for _, v := range importCalls(allRegions) {
+		if !slices.Contains(actions, v.action) {
+			actions = append(actions, v.action)
+		}
+	}
+	slices.Sort(actions)
+	return actions
+}
+
+// AccessCheck is result of aws api call that import needs, Err is nil if the call is allowed
+type AccessCheck struct {
+	Profile string
+	Region  string
+	Action  string
+	Err     error
+}
+
+func (c AccessCheck) Allowed() bool {
+	return c.Err == nil
+}
+
+// Fix returns suggested fix of failed check, empty string if the call is allowed
+func (c AccessCheck) Fix() string {
+	if c.Err == nil {
+		return ""
+	}
+	var apiErr smithy.APIError
+	if !errors.As(c.Err, &apiErr) {
+		return "check aws credentials of the profile (e.g. run 'aws sso login') and network connection"
+	}
+	switch apiErr.ErrorCode() {
+	case "AccessDenied", "AccessDeniedException", "UnauthorizedOperation", "UnauthorizedAccess", "AuthorizationError":
+		return fmt.Sprintf("allow %s in IAM policy of the role (or user) used by import (see 'awf iam-policy'), and check SCPs of the account", c.Action)
+	case "ExpiredToken", "ExpiredTokenException", "InvalidClientTokenId", "UnrecognizedClientException", "AuthFailure", "SignatureDoesNotMatch":
+		return "refresh aws credentials of the profile, e.g. run 'aws sso login'"
+	case "OptInRequired":
+		return fmt.Sprintf("enable region %s in the account, or import other region", c.Region)
+	}
+	return "check the error, and retry"
+}
+
+// CheckAccess calls every aws api that import of supplied profile needs, calls are made in the region from aws config
+// (or the first region in options, see ImportOptions). If the profile is empty, default aws config chain is used.
+// Calls return minimal result, nothing is imported.
+func CheckAccess(profile string, opts ImportOptions) ([]AccessCheck, error) {
+	cfg, err := newAwsConfig(profile, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	calls := importCalls(true)
+	checks := make([]AccessCheck, len(calls))
+	var wg sync.WaitGroup
+	for n, c := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[n] = AccessCheck{Profile: profile, Region: cfg.Region, Action: c.action, Err: c.check(cfg, opts.Timeout)}
+		}()
+	}
+	wg.Wait()
+	return checks, nil
+}
+
+func checkGetCallerIdentity(cfg aws.Config, timeout time.Duration) error {
+	svc := sts.NewFromConfig(cfg)
+	_, err := callWithTimeout(timeout, func(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+		return svc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	})
+	return err
+}
+
+func checkListAccountAliases(cfg aws.Config, timeout time.Duration) error {
+	svc := iam.NewFromConfig(cfg)
+	_, err := callWithTimeout(timeout, func(ctx context.Context) (*iam.ListAccountAliasesOutput, error) {
+		return svc.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	})
+	return err
+}
+
+func checkDescribeRegions(cfg aws.Config, timeout time.Duration) error {
+	svc := ec2.NewFromConfig(cfg)
+	_, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeRegionsOutput, error) {
+		return svc.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	})
+	return err
+}
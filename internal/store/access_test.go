@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAccessCheck_Fix(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "allowed"},
+		{
+			name:     "access denied",
+			err:      &smithy.GenericAPIError{Code: "UnauthorizedOperation"},
+			expected: "allow ec2:DescribeVpcs in IAM policy of the role (or user) used by import (see 'awf iam-policy'), and check SCPs of the account",
+		},
+		{
+			name:     "expired token",
+			err:      &smithy.GenericAPIError{Code: "ExpiredToken"},
+			expected: "refresh aws credentials of the profile, e.g. run 'aws sso login'",
+		},
+		{
+			name:     "no api error",
+			err:      errors.New("failed to refresh cached credentials"),
+			expected: "check aws credentials of the profile (e.g. run 'aws sso login') and network connection",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := AccessCheck{Action: "ec2:DescribeVpcs", Region: "eu-west-2", Err: tt.err}
+			assert.Equal(t, tt.err == nil, check.Allowed())
+			assert.Equal(t, tt.expected, check.Fix())
+		})
+	}
+}
+
+func TestImportActions(t *testing.T) {
+	assert.Equal(t, []string{
+		"ec2:DescribeNetworkInterfaces",
+		"ec2:DescribeSubnets",
+		"ec2:DescribeVpcs",
+		"iam:ListAccountAliases",
+		"sts:GetCallerIdentity",
+	}, ImportActions(false))
+	assert.Contains(t, ImportActions(true), "ec2:DescribeRegions")
+}
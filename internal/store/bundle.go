@@ -0,0 +1,546 @@
+package store
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pete911/awf/internal/types"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// BundleVersion is version of bundle format (layout and manifest), resources in the bundle are in SchemaVersion
+	BundleVersion = 1
+
+	manifestFile = "manifest.json"
+)
+
+var (
+	// accountIdPattern and regionPattern validate account IDs and regions of bundle snapshots, they are used in store
+	// paths, so bundle cannot write outside the store
+	accountIdPattern = regexp.MustCompile(`^[0-9]{12}$`)
+	regionPattern    = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+)
+
+// emptyResources are written for resources that have no items, see readBundleResources
+var emptyResources = map[string]any{
+	ec2VpcsKey:              types.Vpcs{},
+	ec2SubnetsKey:           types.Subnets{},
+	ec2NetworkInterfacesKey: types.NetworkInterfaces{},
+}
+
+// bundleResources are resource names accepted by bundle filter, short names (used by search commands) or stored names
+var bundleResources = map[string]string{
+	"vpc":    ec2VpcsKey,
+	"subnet": ec2SubnetsKey,
+	"ni":     ec2NetworkInterfacesKey,
+}
+
+// BundleFilter selects data that are exported to the bundle, empty field selects everything. Accounts are account IDs,
+// Resources are vpc, subnet or ni.
+type BundleFilter struct {
+	Accounts  []string
+	Regions   []string
+	Resources []string
+}
+
+func (f BundleFilter) resourceNames() ([]string, error) {
+	if len(f.Resources) == 0 {
+		return resourceKeys, nil
+	}
+	var out []string
+	for _, v := range f.Resources {
+		name, ok := bundleResources[v]
+		if !ok {
+			if !slices.Contains(resourceKeys, v) {
+				return nil, fmt.Errorf("unknown resource %s, use vpc, subnet or ni", v)
+			}
+			name = v
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func matchFilter(values []string, v string) bool {
+	return len(values) == 0 || slices.Contains(values, v)
+}
+
+// Manifest describes content of the bundle. Snapshots are current imports of exported account regions with their import
+// times, Files are checksums of every file in the bundle (except manifest).
+//
+// Bundle is zstd compressed tar archive:
+//
+//	manifest.json
+//	<account>/_account             - account metadata (profile, alias, name)
+//	<account>/<region>/<resource>  - json array of resources, see SchemaVersion
+type Manifest struct {
+	Version       int              `json:"version"`
+	SchemaVersion int              `json:"schema_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Snapshots     []BundleSnapshot `json:"snapshots"`
+	Files         []BundleFile     `json:"files"`
+}
+
+type BundleSnapshot struct {
+	AccountId  string     `json:"account_id"`
+	Region     string     `json:"region"`
+	ImportedAt time.Time  `json:"imported_at"`
+	Resources  []Resource `json:"resources"`
+}
+
+type BundleFile struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Accounts returns IDs of accounts in the bundle
+func (m Manifest) Accounts() []string {
+	var out []string
+	for _, v := range m.Snapshots {
+		if !slices.Contains(out, v.AccountId) {
+			out = append(out, v.AccountId)
+		}
+	}
+	return out
+}
+
+// ExportBundle writes current snapshots of the store account regions selected by filter to the bundle, see Manifest
+func ExportBundle(s Store, w io.Writer, filter BundleFilter) (Manifest, error) {
+	names, err := filter.resourceNames()
+	if err != nil {
+		return Manifest{}, err
+	}
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return Manifest{}, err
+	}
+	data, err := readBundleResources(s, names)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	manifest := Manifest{Version: BundleVersion, SchemaVersion: SchemaVersion, CreatedAt: time.Now().UTC()}
+	files := make(map[string][]byte)
+	addFile := func(name string, v any) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("marshal %s: %w", name, err)
+		}
+		files[name] = b
+		manifest.Files = append(manifest.Files, BundleFile{Name: name, Size: len(b), Sha256: checksum(b)})
+		return nil
+	}
+
+	for _, account := range accounts {
+		if !matchFilter(filter.Accounts, account.Id) {
+			continue
+		}
+		regions, err := s.ListRegions(account)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("account %s: %w", account.Id, err)
+		}
+		var exported bool
+		for _, region := range regions {
+			if !matchFilter(filter.Regions, region) {
+				continue
+			}
+			snapshot, err := exportSnapshot(s, account, region, names)
+			if err != nil {
+				return Manifest{}, fmt.Errorf("account %s region %s: %w", account.Id, region, err)
+			}
+			if len(snapshot.Resources) == 0 {
+				continue
+			}
+			for _, resource := range snapshot.Resources {
+				v, ok := data[resource.Name][account.Id+"/"+region]
+				if !ok {
+					v = []any{}
+				}
+				if err := addFile(path.Join(account.Id, region, resource.Name), v); err != nil {
+					return Manifest{}, err
+				}
+			}
+			manifest.Snapshots = append(manifest.Snapshots, snapshot)
+			exported = true
+		}
+		if exported {
+			if err := addFile(path.Join(account.Id, accountFile), account); err != nil {
+				return Manifest{}, err
+			}
+		}
+	}
+	return manifest, writeBundle(w, manifest, files)
+}
+
+// exportSnapshot returns current snapshot of the region with selected resources. Snapshot time is truncated to
+// milliseconds (precision of file store), so the same snapshot exported from any store has the same time.
+func exportSnapshot(s Store, account types.Account, region string, names []string) (BundleSnapshot, error) {
+	resources, err := s.ListResources(account, region)
+	if err != nil {
+		return BundleSnapshot{}, err
+	}
+	importedAt, err := regionImportTime(s, account, region)
+	if err != nil {
+		return BundleSnapshot{}, err
+	}
+
+	snapshot := BundleSnapshot{AccountId: account.Id, Region: region, ImportedAt: importedAt.UTC().Truncate(time.Millisecond)}
+	for _, resource := range resources {
+		if slices.Contains(names, resource.Name) {
+			snapshot.Resources = append(snapshot.Resources, resource)
+		}
+	}
+	return snapshot, nil
+}
+
+// readBundleResources reads selected resources of all account regions, resources are grouped by name and
+// <account>/<region>
+func readBundleResources(s Store, names []string) (map[string]map[string]any, error) {
+	out := make(map[string]map[string]any)
+	if slices.Contains(names, ec2VpcsKey) {
+		vpcs, err := s.DescribeVpcs()
+		if err != nil {
+			return nil, err
+		}
+		out[ec2VpcsKey] = groupByRegion(vpcs, func(v types.Vpc) string { return v.Account.Id + "/" + v.Region })
+	}
+	if slices.Contains(names, ec2SubnetsKey) {
+		subnets, err := s.DescribeSubnets()
+		if err != nil {
+			return nil, err
+		}
+		out[ec2SubnetsKey] = groupByRegion(subnets, func(v types.Subnet) string { return v.Account.Id + "/" + v.Region })
+	}
+	if slices.Contains(names, ec2NetworkInterfacesKey) {
+		nis, err := s.DescribeNetworkInterfaces()
+		if err != nil {
+			return nil, err
+		}
+		out[ec2NetworkInterfacesKey] = groupByRegion(nis, func(v types.NetworkInterface) string {
+			return v.Account.Id + "/" + v.Region
+		})
+	}
+	return out, nil
+}
+
+func groupByRegion[S ~[]E, E any](in S, key func(E) string) map[string]any {
+	groups := make(map[string]S)
+	for _, v := range in {
+		groups[key(v)] = append(groups[key(v)], v)
+	}
+	out := make(map[string]any, len(groups))
+	for k, v := range groups {
+		out[k] = v
+	}
+	return out
+}
+
+func writeBundle(w io.Writer, manifest Manifest, files map[string][]byte) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := writeTarFile(tw, manifestFile, b, manifest.CreatedAt); err != nil {
+		return err
+	}
+	for _, file := range manifest.Files {
+		if err := writeTarFile(tw, file.Name, files[file.Name], manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(b)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write bundle %s: %w", name, err)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return fmt.Errorf("write bundle %s: %w", name, err)
+	}
+	return nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Bundle is bundle read by ReadBundle, its content was verified against the manifest checksums
+type Bundle struct {
+	Manifest Manifest
+	files    map[string][]byte
+}
+
+// ReadBundle reads and verifies bundle, bundle that does not match its manifest (missing, unexpected or corrupted file)
+// is refused
+func ReadBundle(r io.Reader) (Bundle, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("read bundle: %w", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Bundle{}, fmt.Errorf("read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("read bundle %s: %w", header.Name, err)
+		}
+		files[header.Name] = b
+	}
+
+	b, ok := files[manifestFile]
+	if !ok {
+		return Bundle{}, errors.New("read bundle: manifest not found, file is not awf bundle")
+	}
+	delete(files, manifestFile)
+	var manifest Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return Bundle{}, fmt.Errorf("read bundle manifest: %w", err)
+	}
+	if manifest.Version != BundleVersion {
+		return Bundle{}, fmt.Errorf("bundle version %d is not supported, supported version is %d", manifest.Version, BundleVersion)
+	}
+	if err := checkSchemaVersion(manifest.SchemaVersion); err != nil {
+		return Bundle{}, err
+	}
+	if err := verifyBundle(manifest, files); err != nil {
+		return Bundle{}, err
+	}
+	return Bundle{Manifest: manifest, files: files}, nil
+}
+
+func verifyBundle(manifest Manifest, files map[string][]byte) error {
+	expected := make(map[string]bool)
+	for _, file := range manifest.Files {
+		if path.IsAbs(file.Name) || strings.Contains(file.Name, "..") {
+			return fmt.Errorf("verify bundle: invalid file name %s", file.Name)
+		}
+		b, ok := files[file.Name]
+		if !ok {
+			return fmt.Errorf("verify bundle: file %s is missing", file.Name)
+		}
+		if len(b) != file.Size || checksum(b) != file.Sha256 {
+			return fmt.Errorf("verify bundle: file %s checksum does not match manifest", file.Name)
+		}
+		expected[file.Name] = true
+	}
+	for name := range files {
+		if !expected[name] {
+			return fmt.Errorf("verify bundle: unexpected file %s", name)
+		}
+	}
+	for _, snapshot := range manifest.Snapshots {
+		if !accountIdPattern.MatchString(snapshot.AccountId) {
+			return fmt.Errorf("verify bundle: invalid account id %q", snapshot.AccountId)
+		}
+		if !regionPattern.MatchString(snapshot.Region) {
+			return fmt.Errorf("verify bundle: account %s invalid region %q", snapshot.AccountId, snapshot.Region)
+		}
+		accountPath := path.Join(snapshot.AccountId, accountFile)
+		if !expected[accountPath] {
+			return fmt.Errorf("verify bundle: account %s metadata is missing", snapshot.AccountId)
+		}
+		var account types.Account
+		if err := json.Unmarshal(files[accountPath], &account); err != nil {
+			return fmt.Errorf("verify bundle: account %s metadata: %w", snapshot.AccountId, err)
+		}
+		if account.Id != snapshot.AccountId {
+			return fmt.Errorf("verify bundle: account %s metadata has account id %q", snapshot.AccountId, account.Id)
+		}
+		for _, resource := range snapshot.Resources {
+			if !expected[path.Join(snapshot.AccountId, snapshot.Region, resource.Name)] {
+				return fmt.Errorf("verify bundle: account %s region %s %s is missing", snapshot.AccountId, snapshot.Region, resource.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// BundleResult is result of bundle load, number of loaded snapshots and snapshots that were already in the store
+type BundleResult struct {
+	Loaded  int
+	Skipped int
+}
+
+// Load merges bundle snapshots into the store. Snapshots keep their import time, snapshot that is already in the store
+// (the same account, region and import time) is skipped, so the same bundle can be loaded again. Snapshot older than
+// the current import is added to the history. Resources that are not in the bundle (bundle exported with resource
+// filter) are carried over from the local snapshot. Account metadata are written only for accounts that are not in
+// the store, so local profiles are kept.
+func (b Bundle) Load(s Store) (BundleResult, error) {
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		// file store directory is created by the first write
+		var notFound *NotFoundError
+		if !errors.As(err, &notFound) {
+			return BundleResult{}, err
+		}
+	}
+
+	var result BundleResult
+	for _, snapshot := range b.Manifest.Snapshots {
+		account := accounts.GetById(snapshot.AccountId)
+		if account.Id == "" {
+			if err := json.Unmarshal(b.files[path.Join(snapshot.AccountId, accountFile)], &account); err != nil {
+				return result, fmt.Errorf("account %s: %w", snapshot.AccountId, err)
+			}
+			// bundle is verified by ReadBundle, account id is checked again, it is used in store paths
+			if account.Id != snapshot.AccountId || !accountIdPattern.MatchString(account.Id) {
+				return result, fmt.Errorf("account %s: invalid account id %q", snapshot.AccountId, account.Id)
+			}
+			if err := s.WriteAccount(account); err != nil {
+				return result, err
+			}
+			accounts = append(accounts, account)
+		}
+
+		loaded, err := b.loadSnapshot(s, account, snapshot)
+		if err != nil {
+			return result, fmt.Errorf("account %s region %s: %w", account.Id, snapshot.Region, err)
+		}
+		if loaded {
+			result.Loaded++
+		} else {
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+func (b Bundle) loadSnapshot(s Store, account types.Account, snapshot BundleSnapshot) (bool, error) {
+	snapshots, err := s.ListSnapshots(account, snapshot.Region)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if slices.ContainsFunc(snapshots, snapshot.ImportedAt.Equal) {
+		return false, nil
+	}
+
+	rw, err := s.NewRegionWriter(account, snapshot.Region, Retention{})
+	if err != nil {
+		return false, err
+	}
+	w, ok := rw.(snapshotWriter)
+	if !ok {
+		return false, errors.Join(errors.New("store does not support loading snapshots"), rw.Discard())
+	}
+	var resources []Resource
+	for _, resource := range snapshot.Resources {
+		items, err := b.writeResource(w, account, snapshot, resource.Name)
+		if err != nil {
+			return false, errors.Join(err, w.Discard())
+		}
+		// number of items is unknown in data imported before resource metadata were introduced
+		resource.Items = items
+		resources = append(resources, resource)
+	}
+	carried, err := carryResources(s.At(snapshot.ImportedAt), w, account, snapshot)
+	if err != nil {
+		return false, errors.Join(err, w.Discard())
+	}
+	w.keepImportTime(snapshot.ImportedAt, append(resources, carried...))
+	return true, w.Commit()
+}
+
+// carryResources writes resources that are not in the bundle snapshot from the local snapshot of the region (s reads
+// snapshots current at bundle snapshot import time), so the loaded snapshot does not hide them. Carried resources
+// keep their local import time.
+func carryResources(s Store, w RegionWriter, account types.Account, snapshot BundleSnapshot) ([]Resource, error) {
+	local, err := s.ListResources(account, snapshot.Region)
+	if err != nil {
+		return nil, err
+	}
+	local = slices.DeleteFunc(local, func(v Resource) bool {
+		return slices.ContainsFunc(snapshot.Resources, func(r Resource) bool { return r.Name == v.Name })
+	})
+	if len(local) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	for _, resource := range local {
+		names = append(names, resource.Name)
+	}
+	data, err := readBundleResources(s, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range local {
+		v, ok := data[resource.Name][account.Id+"/"+snapshot.Region]
+		if !ok {
+			v = emptyResources[resource.Name]
+		}
+		if err := w.Write(resource.Name, v, resource.Items); err != nil {
+			return nil, fmt.Errorf("carry %s: %w", resource.Name, err)
+		}
+	}
+	return local, nil
+}
+
+// writeResource writes resource from the bundle to the region writer, number of written items is returned
+func (b Bundle) writeResource(w RegionWriter, account types.Account, snapshot BundleSnapshot, name string) (int, error) {
+	data := b.files[path.Join(snapshot.AccountId, snapshot.Region, name)]
+	version := b.Manifest.SchemaVersion
+
+	var v any
+	var items int
+	var err error
+	switch name {
+	case ec2VpcsKey:
+		var vpcs []types.Vpc
+		vpcs, err = vpcCodec.decode(version, data, account, snapshot.Region)
+		v, items = types.Vpcs(vpcs), len(vpcs)
+	case ec2SubnetsKey:
+		var subnets []types.Subnet
+		subnets, err = subnetCodec.decode(version, data, account, snapshot.Region)
+		v, items = types.Subnets(subnets), len(subnets)
+	case ec2NetworkInterfacesKey:
+		var nis []types.NetworkInterface
+		nis, err = networkInterfaceCodec.decode(version, data, account, snapshot.Region)
+		v, items = types.NetworkInterfaces(nis), len(nis)
+	default:
+		return 0, fmt.Errorf("unknown resource %s", name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("decode %s: %w", name, err)
+	}
+	return items, w.Write(name, v, items)
+}
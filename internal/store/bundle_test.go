@@ -0,0 +1,190 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	account := types.Account{Id: "123456789012", Profile: "prod"}
+	from := newTestFile(t, account)
+	require.NoError(t, writeTestGeneration(t, from, account.Id, "eu-west-1", "vpc-1").Commit())
+	require.NoError(t, writeTestGeneration(t, from, account.Id, "eu-west-2", "vpc-2").Commit())
+
+	var buf bytes.Buffer
+	manifest, err := ExportBundle(from, &buf, BundleFilter{Regions: []string{"eu-west-2"}, Resources: []string{"vpc"}})
+	require.NoError(t, err)
+	require.Len(t, manifest.Snapshots, 1)
+	assert.Equal(t, "eu-west-2", manifest.Snapshots[0].Region)
+	assert.Len(t, manifest.Files, 2)
+
+	bundle, err := ReadBundle(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// local account metadata are kept, bundle snapshot older than local import is added to the history
+	to := newTestFile(t, types.Account{Id: account.Id, Profile: "local"})
+	require.NoError(t, writeTestGeneration(t, to, account.Id, "eu-west-2", "vpc-3").Commit())
+	result, err := bundle.Load(to)
+	require.NoError(t, err)
+	assert.Equal(t, BundleResult{Loaded: 1}, result)
+
+	accounts, err := to.ListAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, "local", accounts[0].Profile)
+	vpcs, err := to.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-3"}, vpcIds(vpcs))
+	vpcs, err = to.At(manifest.Snapshots[0].ImportedAt).DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-2"}, vpcIds(vpcs))
+
+	result, err = bundle.Load(to)
+	require.NoError(t, err)
+	assert.Equal(t, BundleResult{Skipped: 1}, result)
+}
+
+func TestBundle_partial(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	to := newTestFile(t, account)
+	require.NoError(t, writeTestGeneration(t, to, account.Id, "eu-west-2", "vpc-1").Commit())
+
+	// bundle with network interfaces only, imported after the local import (snapshot times have millisecond precision)
+	time.Sleep(10 * time.Millisecond)
+	from := newTestFile(t, account)
+	require.NoError(t, writeTestGeneration(t, from, account.Id, "eu-west-2", "vpc-2").Commit())
+	var buf bytes.Buffer
+	_, err := ExportBundle(from, &buf, BundleFilter{Resources: []string{"ni"}})
+	require.NoError(t, err)
+	bundle, err := ReadBundle(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	result, err := bundle.Load(to)
+	require.NoError(t, err)
+	assert.Equal(t, BundleResult{Loaded: 1}, result)
+
+	// local vpcs and subnets are carried over to the loaded snapshot
+	snapshots, err := to.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	vpcs, err := to.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+	_, err = to.DescribeSubnets()
+	require.NoError(t, err)
+	assert.Empty(t, to.Warnings())
+}
+
+func TestReadBundle_verify(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	from := newTestFile(t, account)
+	require.NoError(t, writeTestGeneration(t, from, account.Id, "eu-west-2", "vpc-1").Commit())
+	var buf bytes.Buffer
+	manifest, err := ExportBundle(from, &buf, BundleFilter{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		files  map[string]string
+		errMsg string
+	}{
+		{name: "corrupted", files: map[string]string{"123456789012/eu-west-2/ec2.describe-vpcs": "[]"}, errMsg: "checksum does not match"},
+		{name: "unexpected", files: map[string]string{"123456789012/eu-west-2/other": "[]"}, errMsg: "unexpected file"},
+		{name: "missing manifest", files: map[string]string{manifestFile: ""}, errMsg: "manifest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := rewriteTestBundle(t, buf.Bytes(), manifest.CreatedAt, tt.files)
+			_, err := ReadBundle(bytes.NewReader(b))
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestReadBundle_invalidIds(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot BundleSnapshot
+		account  types.Account
+		errMsg   string
+	}{
+		{
+			name:     "account id path",
+			snapshot: BundleSnapshot{AccountId: "../../x", Region: "eu-west-2"},
+			account:  types.Account{Id: "../../x"},
+			errMsg:   "invalid",
+		},
+		{
+			name:     "account id",
+			snapshot: BundleSnapshot{AccountId: "prod", Region: "eu-west-2"},
+			account:  types.Account{Id: "prod"},
+			errMsg:   "invalid account id",
+		},
+		{
+			name:     "region path",
+			snapshot: BundleSnapshot{AccountId: "123456789012", Region: "../../x"},
+			account:  types.Account{Id: "123456789012"},
+			errMsg:   "invalid region",
+		},
+		{
+			name:     "account metadata id",
+			snapshot: BundleSnapshot{AccountId: "123456789012", Region: "us-gov-west-1"},
+			account:  types.Account{Id: "../../x"},
+			errMsg:   "metadata has account id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.account)
+			require.NoError(t, err)
+			name := path.Join(tt.snapshot.AccountId, accountFile)
+			manifest := Manifest{
+				Version:       BundleVersion,
+				SchemaVersion: SchemaVersion,
+				Snapshots:     []BundleSnapshot{tt.snapshot},
+				Files:         []BundleFile{{Name: name, Size: len(b), Sha256: checksum(b)}},
+			}
+			var buf bytes.Buffer
+			require.NoError(t, writeBundle(&buf, manifest, map[string][]byte{name: b}))
+
+			_, err = ReadBundle(&buf)
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+// rewriteTestBundle replaces (or adds) bundle files, file with empty content is removed
+func rewriteTestBundle(t *testing.T, in []byte, modTime time.Time, files map[string]string) []byte {
+	zr, err := zstd.NewReader(bytes.NewReader(in))
+	require.NoError(t, err)
+	defer zr.Close()
+
+	var out bytes.Buffer
+	zw, err := zstd.NewWriter(&out)
+	require.NoError(t, err)
+	tw := tar.NewWriter(zw)
+	tr := tar.NewReader(zr)
+	for header, err := tr.Next(); err == nil; header, err = tr.Next() {
+		var b bytes.Buffer
+		_, err := b.ReadFrom(tr)
+		require.NoError(t, err)
+		if _, ok := files[header.Name]; !ok {
+			require.NoError(t, writeTarFile(tw, header.Name, b.Bytes(), modTime))
+		}
+	}
+	for name, content := range files {
+		if content != "" {
+			require.NoError(t, writeTarFile(tw, name, []byte(content), modTime))
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+	return out.Bytes()
+}
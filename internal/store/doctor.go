@@ -0,0 +1,370 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	storeCheck      = "store"
+	accountsCheck   = "account files"
+	duplicatesCheck = "duplicate accounts"
+	schemaCheck     = "schema version"
+	resourcesCheck  = "resource files"
+	stagingCheck    = "unfinished imports"
+)
+
+// checkNames are store checks in the order they are reported
+var checkNames = []string{storeCheck, accountsCheck, duplicatesCheck, schemaCheck, resourcesCheck, stagingCheck}
+
+// resourceDecoders decode stored resource in supplied schema version, they are used to validate stored resources
+var resourceDecoders = map[string]func(version int, b []byte) error{
+	ec2VpcsKey:              decodeCheck(vpcCodec),
+	ec2SubnetsKey:           decodeCheck(subnetCodec),
+	ec2NetworkInterfacesKey: decodeCheck(networkInterfaceCodec),
+}
+
+func decodeCheck[T, S any](c codec[T, S]) func(version int, b []byte) error {
+	return func(version int, b []byte) error {
+		_, err := c.decode(version, b, types.Account{}, "")
+		return err
+	}
+}
+
+// Check is result of one store check, the check passed if it found no problems
+type Check struct {
+	Name     string
+	Problems []Problem
+}
+
+func (c Check) Passed() bool {
+	return len(c.Problems) == 0
+}
+
+// Problem is problem found by check. Path is the file or directory (database for sqlite backend) with the problem,
+// Fix is suggested fix.
+type Problem struct {
+	Path    string
+	Message string
+	Fix     string
+}
+
+// report collects problems of store checks
+type report map[string][]Problem
+
+func (r report) add(check, path, message, fix string) {
+	r[check] = append(r[check], Problem{Path: path, Message: message, Fix: fix})
+}
+
+func (r report) checks() []Check {
+	var out []Check
+	for _, name := range checkNames {
+		out = append(out, Check{Name: name, Problems: r[name]})
+	}
+	return out
+}
+
+// CheckStore checks integrity of stores selected by options (see Load): that every account has readable account
+// file, every snapshot has supported schema version and all resources written by importers, and that accounts are
+// not stored more than once. Checks do not change the store.
+func CheckStore(opts Options) ([]Check, error) {
+	dir := opts.Dir
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			return nil, err
+		}
+	}
+	dirs, err := ResolveWorkspaces(dir, opts.Workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	r := report{}
+	// workspace directories of every account, account is duplicate if it is stored more than once
+	accounts := make(map[string][]string)
+	for _, workspaceDir := range dirs {
+		path := workspaceDir
+		if opts.Backend == SqliteBackend {
+			path = filepath.Join(workspaceDir, sqliteFile)
+		}
+		if _, err := os.Stat(path); err != nil {
+			r.add(storeCheck, path, "store does not exist", "run 'awf import' to import data, or check --store-dir and --workspace flags")
+			continue
+		}
+
+		s, err := load(opts, workspaceDir)
+		if err != nil {
+			r.add(storeCheck, path, err.Error(), "check store backend and encryption settings (key file or passphrase)")
+			continue
+		}
+		var ids []string
+		switch v := s.(type) {
+		case File:
+			ids = v.check(r)
+		case Sqlite:
+			ids = v.check(r)
+			v.Close()
+		}
+		for _, id := range ids {
+			accounts[id] = append(accounts[id], path)
+		}
+	}
+
+	for id, paths := range accounts {
+		if len(paths) > 1 {
+			r.add(duplicatesCheck, strings.Join(paths, ", "), fmt.Sprintf("account %s is stored %d times", id, len(paths)),
+				fmt.Sprintf("remove account %s from all but one workspace by 'awf store remove --accounts %s --workspace <name>'", id, id))
+		}
+	}
+	for _, problems := range r {
+		slices.SortFunc(problems, func(a, b Problem) int { return strings.Compare(a.Path, b.Path) })
+	}
+	return r.checks(), nil
+}
+
+// check checks account and resource files of every account and region, IDs of stored accounts are returned
+func (f File) check(r report) []string {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		r.add(storeCheck, f.dir, err.Error(), "check permissions of the store directory")
+		return nil
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || e.Name() == workspacesDir {
+			continue
+		}
+		accountDir := filepath.Join(f.dir, e.Name())
+		account, ok := f.checkAccount(r, accountDir)
+		if !ok {
+			continue
+		}
+		ids = append(ids, account.Id)
+
+		regions, err := f.ListRegions(account)
+		if err != nil {
+			r.add(storeCheck, accountDir, err.Error(), "check permissions of the account directory")
+			continue
+		}
+		for _, region := range regions {
+			f.checkRegion(r, account, region)
+		}
+	}
+	return ids
+}
+
+// checkAccount checks account file in the account directory, account is not ok if the account file cannot be read
+func (f File) checkAccount(r report, accountDir string) (types.Account, bool) {
+	accountId := filepath.Base(accountDir)
+	path := filepath.Join(accountDir, accountFile)
+	fix := fmt.Sprintf("run 'awf import' with account %s credentials to write the account file, or delete %s directory", accountId, accountDir)
+
+	b, err := f.enc.readFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			r.add(accountsCheck, path, "account file does not exist", fix)
+			return types.Account{}, false
+		}
+		r.add(accountsCheck, path, err.Error(), fix)
+		return types.Account{}, false
+	}
+	var account types.Account
+	if err := json.Unmarshal(b, &account); err != nil {
+		r.add(accountsCheck, path, fmt.Sprintf("invalid json: %v", err), fix)
+		return types.Account{}, false
+	}
+	if account.Id != accountId {
+		r.add(accountsCheck, path, fmt.Sprintf("account id %s does not match directory %s", account.Id, accountId), fix)
+		return types.Account{}, false
+	}
+	return account, true
+}
+
+// checkRegion checks schema version and resource files of every generation (snapshot) of the region
+func (f File) checkRegion(r report, account types.Account, region string) {
+	regionDir := filepath.Join(f.dir, account.Id, region)
+	regionFix := fmt.Sprintf("re-import the region by 'awf import --regions %s' with account %s credentials, or remove it by 'awf store remove --accounts %s --regions %s'",
+		region, account.Id, account.Id, region)
+
+	current := currentGeneration(regionDir)
+	if _, err := os.Stat(current); err != nil {
+		r.add(resourcesCheck, current, "current snapshot does not exist", regionFix)
+	}
+
+	snapshots, err := listGenerations(regionDir)
+	if err != nil {
+		r.add(storeCheck, regionDir, err.Error(), "check permissions of the region directory")
+		return
+	}
+	// staging directories of running import are locked by the import
+	if !isLocked(lockPath(f.dir, account.Id, region)) {
+		staging, err := listStaging(regionDir)
+		if err != nil {
+			r.add(storeCheck, regionDir, err.Error(), "check permissions of the region directory")
+		}
+		for _, dir := range staging {
+			r.add(stagingCheck, dir, "staging directory of import that did not finish",
+				fmt.Sprintf("re-import the region by 'awf import --regions %s' with account %s credentials (import removes it), or delete the directory", region, account.Id))
+		}
+	}
+	// regions imported before generations were introduced have files in the region directory
+	generationDirs := []string{regionDir}
+	if len(snapshots) > 0 {
+		generationDirs = nil
+		for _, snapshot := range snapshots {
+			generationDirs = append(generationDirs, filepath.Join(regionDir, snapshot.Format(generationFormat)))
+		}
+	}
+
+	for _, generationDir := range generationDirs {
+		fix := regionFix
+		if generationDir != current {
+			fix = fmt.Sprintf("remove old snapshots by 'awf store prune --accounts %s --regions %s --keep-snapshots 1'", account.Id, region)
+		}
+		f.checkGeneration(r, generationDir, fix)
+	}
+}
+
+// checkGeneration checks that generation has supported schema version, and that its metadata and every resource
+// written by importers can be decoded
+func (f File) checkGeneration(r report, generationDir, fix string) {
+	version, err := readSchemaVersion(f.enc, generationDir)
+	if err != nil {
+		var schemaErr *SchemaError
+		if errors.As(err, &schemaErr) && schemaErr.Version > SchemaVersion {
+			fix = "upgrade awf, data were imported by newer version"
+		}
+		r.add(schemaCheck, filepath.Join(generationDir, schemaFile), err.Error(), fix)
+		return
+	}
+
+	if _, err := readResources(f.enc, generationDir); err != nil {
+		r.add(resourcesCheck, filepath.Join(generationDir, metaFile), err.Error(), fix)
+	}
+	for _, key := range resourceKeys {
+		path := filepath.Join(generationDir, key)
+		b, err := f.enc.readFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				r.add(resourcesCheck, path, "resource file does not exist", fix)
+				continue
+			}
+			r.add(resourcesCheck, path, err.Error(), fix)
+			continue
+		}
+		if err := resourceDecoders[key](version, b); err != nil {
+			r.add(resourcesCheck, path, fmt.Sprintf("invalid json: %v", err), fix)
+		}
+	}
+}
+
+// check checks database integrity, account data, schema version and resources of current snapshots, IDs of stored
+// accounts are returned
+func (s Sqlite) check(r report) []string {
+	var result string
+	if err := s.db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil || result != "ok" {
+		if err != nil {
+			result = err.Error()
+		}
+		r.add(storeCheck, s.path, fmt.Sprintf("database integrity check: %s", result),
+			fmt.Sprintf("restore %s from backup, or delete it and run 'awf import' again", s.path))
+		return nil
+	}
+
+	rows, err := s.db.Query("SELECT id, data FROM accounts ORDER BY id")
+	if err != nil {
+		r.add(storeCheck, s.path, err.Error(), "check permissions of the database file")
+		return nil
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			r.add(storeCheck, s.path, err.Error(), "check permissions of the database file")
+			return nil
+		}
+		ids = append(ids, id)
+		var account types.Account
+		if err := json.Unmarshal([]byte(data), &account); err != nil {
+			r.add(accountsCheck, s.path, fmt.Sprintf("account %s: invalid json: %v", id, err),
+				fmt.Sprintf("run 'awf import' with account %s credentials to write account data", id))
+		}
+	}
+
+	s.checkUncommitted(r)
+	snapshots, err := s.snapshots("")
+	if err != nil {
+		r.add(resourcesCheck, s.path, err.Error(), "re-import affected account region, or remove it by 'awf store remove'")
+		return ids
+	}
+	for _, snapshot := range snapshots {
+		fix := fmt.Sprintf("re-import the region by 'awf import --regions %s' with account %s credentials, or remove it by 'awf store remove --accounts %s --regions %s'",
+			snapshot.region, snapshot.accountId, snapshot.accountId, snapshot.region)
+		if !slices.Contains(ids, snapshot.accountId) {
+			r.add(accountsCheck, s.path, fmt.Sprintf("account %s: account data do not exist", snapshot.accountId),
+				fmt.Sprintf("run 'awf import' with account %s credentials to write account data", snapshot.accountId))
+		}
+		if err := checkSchemaVersion(snapshot.schemaVersion); err != nil {
+			if snapshot.schemaVersion > SchemaVersion {
+				fix = "upgrade awf, data were imported by newer version"
+			}
+			r.add(schemaCheck, s.path, fmt.Sprintf("account %s region %s: %v", snapshot.accountId, snapshot.region, err), fix)
+			continue
+		}
+		for _, key := range resourceKeys {
+			if !slices.ContainsFunc(snapshot.resources, func(v Resource) bool { return v.Name == key }) {
+				r.add(resourcesCheck, s.path, fmt.Sprintf("account %s region %s: resource %s does not exist", snapshot.accountId, snapshot.region, key), fix)
+			}
+		}
+		if err := s.checkResources(snapshot); err != nil {
+			r.add(resourcesCheck, s.path, fmt.Sprintf("account %s region %s: %v", snapshot.accountId, snapshot.region, err), fix)
+		}
+	}
+	return ids
+}
+
+// checkUncommitted reports uncommitted snapshots of imports that did not finish, snapshots of running imports are
+// locked by the import
+func (s Sqlite) checkUncommitted(r report) {
+	rows, err := s.db.Query("SELECT DISTINCT account_id, region FROM snapshots WHERE committed = 0 ORDER BY account_id, region")
+	if err != nil {
+		r.add(storeCheck, s.path, err.Error(), "check permissions of the database file")
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var accountId, region string
+		if err := rows.Scan(&accountId, &region); err != nil {
+			r.add(storeCheck, s.path, err.Error(), "check permissions of the database file")
+			return
+		}
+		if isLocked(lockPath(filepath.Dir(s.path), accountId, region)) {
+			continue
+		}
+		r.add(stagingCheck, s.path, fmt.Sprintf("account %s region %s: uncommitted snapshot of import that did not finish", accountId, region),
+			fmt.Sprintf("re-import the region by 'awf import --regions %s' with account %s credentials (import removes it)", region, accountId))
+	}
+}
+
+// checkResources decodes every resource of the snapshot
+func (s Sqlite) checkResources(snapshot sqliteSnapshot) error {
+	if _, err := queryResources(s.db, vpcCodec, types.Account{}, snapshot, "SELECT data FROM vpcs WHERE snapshot_id = ?", snapshot.id); err != nil {
+		return fmt.Errorf("vpcs: %w", err)
+	}
+	if _, err := queryResources(s.db, subnetCodec, types.Account{}, snapshot, "SELECT data FROM subnets WHERE snapshot_id = ?", snapshot.id); err != nil {
+		return fmt.Errorf("subnets: %w", err)
+	}
+	if _, err := queryResources(s.db, networkInterfaceCodec, types.Account{}, snapshot, "SELECT data FROM network_interfaces WHERE snapshot_id = ?", snapshot.id); err != nil {
+		return fmt.Errorf("network interfaces: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		corrupt  func(t *testing.T, dir, generationDir string)
+		expected string
+	}{
+		{name: "healthy", corrupt: func(t *testing.T, dir, generationDir string) {}},
+		{
+			name: "missing account file",
+			corrupt: func(t *testing.T, dir, _ string) {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "210987654321"), 0700))
+			},
+			expected: accountsCheck,
+		},
+		{
+			name: "account id does not match directory",
+			corrupt: func(t *testing.T, dir, _ string) {
+				require.NoError(t, os.Rename(filepath.Join(dir, "123456789012"), filepath.Join(dir, "210987654321")))
+			},
+			expected: accountsCheck,
+		},
+		{
+			name: "unsupported schema version",
+			corrupt: func(t *testing.T, _, generationDir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(generationDir, schemaFile), []byte(`{"version": 9}`), 0600))
+			},
+			expected: schemaCheck,
+		},
+		{
+			name: "missing resource file",
+			corrupt: func(t *testing.T, _, generationDir string) {
+				require.NoError(t, os.Remove(filepath.Join(generationDir, ec2SubnetsKey)))
+			},
+			expected: resourcesCheck,
+		},
+		{
+			name: "invalid resource file",
+			corrupt: func(t *testing.T, _, generationDir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(generationDir, ec2VpcsKey), []byte("{"), 0600))
+			},
+			expected: resourcesCheck,
+		},
+		{
+			name: "unfinished import",
+			corrupt: func(t *testing.T, dir, _ string) {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "123456789012", "eu-west-2", stagingPrefix+"1"), 0700))
+			},
+			expected: stagingCheck,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := types.Account{Id: "123456789012"}
+			f := newTestFile(t, account)
+			require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-1").Commit())
+			tt.corrupt(t, f.dir, currentGeneration(filepath.Join(f.dir, account.Id, "eu-west-2")))
+
+			checks, err := CheckStore(Options{Dir: f.dir})
+			require.NoError(t, err)
+			for _, check := range checks {
+				assert.Equal(t, check.Name != tt.expected, check.Passed(), check.Name)
+			}
+		})
+	}
+}
+
+func TestCheckStore_duplicate(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	require.NoError(t, CreateWorkspace(f.dir, "prod"))
+	prod, err := LoadFile(FileOptions{Dir: WorkspaceDir(f.dir, "prod")})
+	require.NoError(t, err)
+	require.NoError(t, prod.WriteAccount(account))
+
+	checks, err := CheckStore(Options{Dir: f.dir, Workspaces: []string{"all"}})
+	require.NoError(t, err)
+	for _, check := range checks {
+		assert.Equal(t, check.Name != duplicatesCheck, check.Passed(), check.Name)
+	}
+}
+
+func TestCheckStore_sqlite(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	s, err := LoadSqlite(SqliteOptions{Dir: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, s.WriteAccount(account))
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-1").Commit())
+	_, err = s.db.Exec("UPDATE vpcs SET data = '{'")
+	require.NoError(t, err)
+	// import that did not finish
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-1", Retention{}, "vpc-2").(*sqliteRegionWriter).unlock())
+	require.NoError(t, s.Close())
+
+	checks, err := CheckStore(Options{Backend: SqliteBackend, Dir: filepath.Dir(s.path)})
+	require.NoError(t, err)
+	for _, check := range checks {
+		assert.Equal(t, check.Name != resourcesCheck && check.Name != stagingCheck, check.Passed(), check.Name)
+	}
+}
@@ -2,108 +2,181 @@ package store
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/pete911/awf/internal/types"
+	"slices"
 	"sync"
 	"time"
 )
 
-type ec2Importer func(svc *ec2.Client) (string, any, error)
+// ec2Importer imports one ec2 resource, action is IAM action of the describe call and check makes the call with
+// minimal (single page) result
+type ec2Importer struct {
+	name     string
+	action   string
+	describe func(svc *ec2.Client, timeout time.Duration, stats *importStats) (any, error)
+	check    func(svc *ec2.Client, timeout time.Duration) error
+}
 
 var ec2Importers = []ec2Importer{
-	describeVpcs,
-	describeSubnets,
-	describeNetworkInterfaces,
+	{name: ec2VpcsKey, action: "ec2:DescribeVpcs", describe: describeVpcs, check: checkDescribeVpcs},
+	{name: ec2SubnetsKey, action: "ec2:DescribeSubnets", describe: describeSubnets, check: checkDescribeSubnets},
+	{name: ec2NetworkInterfacesKey, action: "ec2:DescribeNetworkInterfaces", describe: describeNetworkInterfaces, check: checkDescribeNetworkInterfaces},
+}
+
+// ec2Calls returns aws api calls of ec2 importers
+func ec2Calls() []apiCall {
+	var calls []apiCall
+	for _, i := range ec2Importers {
+		calls = append(calls, apiCall{
+			action: i.action,
+			check: func(cfg aws.Config, timeout time.Duration) error {
+				return i.check(ec2.NewFromConfig(cfg), timeout)
+			},
+		})
+	}
+	return calls
 }
 
-func ec2Import(account types.Account, cfg aws.Config, file File) error {
+func ec2Import(account types.Account, cfg aws.Config, w RegionWriter, opts ImportOptions) []ImportEntry {
 	svc := ec2.NewFromConfig(cfg)
 
-	var hasErrors bool
+	entries := make([]ImportEntry, len(ec2Importers))
 	var wg sync.WaitGroup
-	for _, i := range ec2Importers {
+	for n, i := range ec2Importers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			name, content, err := i(svc)
-			if err != nil {
-				fmt.Println(err.Error())
-				hasErrors = true
-				return
+			start := time.Now()
+			var stats importStats
+			content, err := i.describe(svc, opts.Timeout, &stats)
+			if err == nil && opts.Raw {
+				err = w.WriteRaw(i.name, content)
 			}
-			if err := file.write(account, cfg.Region, name, content); err != nil {
-				fmt.Println(err.Error())
-				hasErrors = true
+			if err == nil {
+				err = w.Write(i.name, normalize(content), stats.Items)
+			}
+			entries[n] = ImportEntry{
+				Profile:   account.Profile,
+				AccountId: account.Id,
+				Region:    cfg.Region,
+				Importer:  i.name,
+				Duration:  time.Since(start),
+				Items:     stats.Items,
+				Pages:     stats.Pages,
+				Retries:   stats.Retries,
+				Err:       err,
 			}
 		}()
 	}
 	wg.Wait()
-	if hasErrors {
-		return errors.New("ec2 import failed")
-	}
-	return nil
+	return entries
 }
 
-func describeVpcs(svc *ec2.Client) (string, any, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// describeRegions returns regions enabled for the account, regions that are not opted in are skipped
+func describeRegions(cfg aws.Config, timeout time.Duration) ([]string, error) {
+	svc := ec2.NewFromConfig(cfg)
+	out, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeRegionsOutput, error) {
+		return svc.DescribeRegions(ctx, &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe regions: %w", err)
+	}
 
+	var regions []string
+	for _, region := range out.Regions {
+		if aws.ToString(region.OptInStatus) == "not-opted-in" {
+			continue
+		}
+		regions = append(regions, aws.ToString(region.RegionName))
+	}
+	slices.Sort(regions)
+	return regions, nil
+}
+
+func describeVpcs(svc *ec2.Client, timeout time.Duration, stats *importStats) (any, error) {
 	var vpcs []ec2types.Vpc
 	in := &ec2.DescribeVpcsInput{}
 	for {
-		out, err := svc.DescribeVpcs(ctx, in)
+		out, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeVpcsOutput, error) {
+			return svc.DescribeVpcs(ctx, in)
+		})
 		if err != nil {
-			return "", nil, err
+			return nil, fmt.Errorf("describe vpcs: %w", err)
 		}
+		stats.add(out.ResultMetadata, len(out.Vpcs))
 		vpcs = append(vpcs, out.Vpcs...)
 		if aws.ToString(out.NextToken) == "" {
 			break
 		}
 		in.NextToken = out.NextToken
 	}
-	return ec2VpcsKey, vpcs, nil
+	return vpcs, nil
 }
 
-func describeSubnets(svc *ec2.Client) (string, any, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
+func describeSubnets(svc *ec2.Client, timeout time.Duration, stats *importStats) (any, error) {
 	var subnets []ec2types.Subnet
 	in := &ec2.DescribeSubnetsInput{}
 	for {
-		out, err := svc.DescribeSubnets(ctx, in)
+		out, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeSubnetsOutput, error) {
+			return svc.DescribeSubnets(ctx, in)
+		})
 		if err != nil {
-			return "", nil, err
+			return nil, fmt.Errorf("describe subnets: %w", err)
 		}
+		stats.add(out.ResultMetadata, len(out.Subnets))
 		subnets = append(subnets, out.Subnets...)
 		if aws.ToString(out.NextToken) == "" {
 			break
 		}
 		in.NextToken = out.NextToken
 	}
-	return ec2SubnetsKey, subnets, nil
+	return subnets, nil
 }
 
-func describeNetworkInterfaces(svc *ec2.Client) (string, any, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
+func describeNetworkInterfaces(svc *ec2.Client, timeout time.Duration, stats *importStats) (any, error) {
 	var nis []ec2types.NetworkInterface
 	in := &ec2.DescribeNetworkInterfacesInput{}
 	for {
-		out, err := svc.DescribeNetworkInterfaces(ctx, in)
+		out, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeNetworkInterfacesOutput, error) {
+			return svc.DescribeNetworkInterfaces(ctx, in)
+		})
 		if err != nil {
-			return "", nil, err
+			return nil, fmt.Errorf("describe network interfaces: %w", err)
 		}
+		stats.add(out.ResultMetadata, len(out.NetworkInterfaces))
 		nis = append(nis, out.NetworkInterfaces...)
 		if aws.ToString(out.NextToken) == "" {
 			break
 		}
 		in.NextToken = out.NextToken
 	}
-	return ec2NetworkInterfacesKey, nis, nil
+	return nis, nil
+}
+
+// ec2CheckMaxResults is the smallest page size that ec2 describe calls accept
+const ec2CheckMaxResults = 5
+
+func checkDescribeVpcs(svc *ec2.Client, timeout time.Duration) error {
+	_, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeVpcsOutput, error) {
+		return svc.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{MaxResults: aws.Int32(ec2CheckMaxResults)})
+	})
+	return err
+}
+
+func checkDescribeSubnets(svc *ec2.Client, timeout time.Duration) error {
+	_, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeSubnetsOutput, error) {
+		return svc.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{MaxResults: aws.Int32(ec2CheckMaxResults)})
+	})
+	return err
+}
+
+func checkDescribeNetworkInterfaces(svc *ec2.Client, timeout time.Duration) error {
+	_, err := callWithTimeout(timeout, func(ctx context.Context) (*ec2.DescribeNetworkInterfacesOutput, error) {
+		return svc.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(ec2CheckMaxResults)})
+	})
+	return err
 }
@@ -0,0 +1,305 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	ZstdCompression = "zstd"
+	GzipCompression = "gzip"
+	NoCompression   = "none"
+
+	// PassphraseEnv is env. variable with passphrase that encrypts the store, see Encoding
+	PassphraseEnv = "AWF_PASSPHRASE"
+	// KeyFileEnv is env. variable with path to age identity (key) file that encrypts the store, see Encoding
+	KeyFileEnv = "AWF_KEY_FILE"
+
+	// storeKeyFile is age identity generated for passphrase encrypted store, the identity is encrypted by the passphrase
+	storeKeyFile = ".key.age"
+)
+
+var (
+	ageMagic  = []byte("age-encryption.org/v1\n")
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+)
+
+// Encoding configures how store files are written. Compression is zstd (default), gzip or none. Files are encrypted
+// (age) if KeyFile (age identity file, e.g. created by age-keygen) or Passphrase is set. Passphrase encrypts store
+// key that is generated on first write (CreateKey is set by commands that write the store), so the expensive
+// passphrase derivation runs once, not for every file. Reads detect format of every file, so files written with
+// different encoding (or before encoding) can be mixed.
+type Encoding struct {
+	Compression string
+	Passphrase  string
+	KeyFile     string
+	CreateKey   bool
+}
+
+// Encrypted returns true if the encoding encrypts the store
+func (e Encoding) Encrypted() bool {
+	return e.Passphrase != "" || e.KeyFile != ""
+}
+
+// EncodingFromEnv returns encoding with passphrase and key file from env. variables, key file from env. variable takes
+// precedence over supplied key file (e.g. from config)
+func EncodingFromEnv(compression, keyFile string) Encoding {
+	if v := os.Getenv(KeyFileEnv); v != "" {
+		keyFile = v
+	}
+	return Encoding{Compression: compression, Passphrase: os.Getenv(PassphraseEnv), KeyFile: keyFile}
+}
+
+// encoder encodes and decodes store files, zero value writes plain json
+type encoder struct {
+	compression string
+	recipients  []age.Recipient
+	identities  []age.Identity
+}
+
+func newEncoder(dir string, e Encoding) (encoder, error) {
+	enc := encoder{compression: e.Compression}
+	switch e.Compression {
+	case "":
+		enc.compression = ZstdCompression
+	case ZstdCompression, GzipCompression, NoCompression:
+	default:
+		return encoder{}, fmt.Errorf("unknown store compression %s, use zstd, gzip or none", e.Compression)
+	}
+
+	var identities []*age.X25519Identity
+	if e.KeyFile != "" {
+		keyIdentities, err := readKeyFile(e.KeyFile)
+		if err != nil {
+			return encoder{}, err
+		}
+		identities = append(identities, keyIdentities...)
+	}
+	if e.Passphrase != "" {
+		identity, err := loadStoreKey(dir, e.Passphrase, e.CreateKey)
+		if err != nil {
+			return encoder{}, err
+		}
+		identities = append(identities, identity)
+	}
+
+	for _, identity := range identities {
+		enc.identities = append(enc.identities, identity)
+		enc.recipients = append(enc.recipients, identity.Recipient())
+	}
+	return enc, nil
+}
+
+// readKeyFile reads X25519 identities from age key file
+func readKeyFile(path string) ([]*age.X25519Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	defer f.Close()
+
+	parsed, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse key file %s: %w", path, err)
+	}
+	var identities []*age.X25519Identity
+	for _, v := range parsed {
+		if identity, ok := v.(*age.X25519Identity); ok {
+			identities = append(identities, identity)
+		}
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("key file %s has no X25519 identity", path)
+	}
+	return identities, nil
+}
+
+// loadStoreKey decrypts store key in the store directory by passphrase. If the key does not exist, it is generated if
+// create is set, otherwise error is returned (nothing was written with the passphrase yet). Key is generated under
+// lock, so concurrent imports of new store use the same key.
+func loadStoreKey(dir, passphrase string, create bool) (*age.X25519Identity, error) {
+	path := filepath.Join(dir, storeKeyFile)
+	identity, err := readStoreKey(path, passphrase)
+	if !errors.Is(err, os.ErrNotExist) {
+		return identity, err
+	}
+	if !create {
+		return nil, fmt.Errorf("store key %s does not exist, nothing was imported with %s yet, run 'awf import' first", path, PassphraseEnv)
+	}
+
+	unlock, err := lock(lockPath(dir, "key"))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// key could be created by other import, while waiting for the lock
+	identity, err = readStoreKey(path, passphrase)
+	if !errors.Is(err, os.ErrNotExist) {
+		return identity, err
+	}
+	return newStoreKey(path, passphrase)
+}
+
+// readStoreKey reads and decrypts store key, error wraps os.ErrNotExist if the key does not exist
+func readStoreKey(path, passphrase string) (*age.X25519Identity, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read store key: %w", err)
+	}
+
+	scryptIdentity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(b), scryptIdentity)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt store key %s (wrong %s?): %w", path, PassphraseEnv, err)
+	}
+	key, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt store key %s: %w", path, err)
+	}
+	return age.ParseX25519Identity(strings.TrimSpace(string(key)))
+}
+
+func newStoreKey(path, passphrase string) (*age.X25519Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	b, err := encrypt([]byte(identity.String()), recipient)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt store key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, b); err != nil {
+		return nil, fmt.Errorf("write store key: %w", err)
+	}
+	return identity, nil
+}
+
+// encode compresses and encrypts content
+func (e encoder) encode(b []byte) ([]byte, error) {
+	switch e.compression {
+	case ZstdCompression:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		b = enc.EncodeAll(b, nil)
+	case GzipCompression:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+
+	if len(e.recipients) == 0 {
+		return b, nil
+	}
+	return encrypt(b, e.recipients...)
+}
+
+// decode decrypts and decompresses content, format is detected by content prefix
+func (e encoder) decode(b []byte) ([]byte, error) {
+	if bytes.HasPrefix(b, ageMagic) {
+		if len(e.identities) == 0 {
+			return nil, fmt.Errorf("file is encrypted, set %s or %s", PassphraseEnv, KeyFileEnv)
+		}
+		r, err := age.Decrypt(bytes.NewReader(b), e.identities...)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(b, zstdMagic):
+		dec, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return dec.DecodeAll(b, nil)
+	case bytes.HasPrefix(b, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return b, nil
+}
+
+// readFile reads and decodes the file
+func (e encoder) readFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = e.decode(b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// writeFile encodes content and writes it to the file, see writeFileAtomic
+func (e encoder) writeFile(path string, b []byte) error {
+	b, err := e.encode(b)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", path, err)
+	}
+	return writeFileAtomic(path, b)
+}
+
+func (e encoder) writeJson(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", path, err)
+	}
+	return e.writeFile(path, b)
+}
+
+func encrypt(b []byte, recipients ...age.Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"filippo.io/age"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	dir := t.TempDir()
+	// store key is created only by writes
+	_, err := newEncoder(dir, Encoding{Passphrase: "secret"})
+	assert.ErrorContains(t, err, "store key")
+	_, err = os.Stat(filepath.Join(dir, storeKeyFile))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "key.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600))
+
+	tests := []struct {
+		name     string
+		encoding Encoding
+	}{
+		{name: "default"},
+		{name: "gzip", encoding: Encoding{Compression: GzipCompression}},
+		{name: "none", encoding: Encoding{Compression: NoCompression}},
+		{name: "key file", encoding: Encoding{KeyFile: keyFile}},
+		{name: "passphrase", encoding: Encoding{Compression: GzipCompression, Passphrase: "secret", CreateKey: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := newEncoder(dir, tt.encoding)
+			require.NoError(t, err)
+			path := filepath.Join(dir, tt.name)
+			require.NoError(t, enc.writeJson(path, types.Vpcs{{VpcId: "vpc-1"}}))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			b, err := enc.readFile(path)
+			require.NoError(t, err)
+			assert.JSONEq(t, `[{"vpc_id":"vpc-1","cidr_block":"","owner_id":"","state":""}]`, string(b))
+
+			// encrypted file cannot be read without the key
+			if len(enc.recipients) > 0 {
+				_, err = encoder{}.readFile(path)
+				assert.ErrorContains(t, err, "file is encrypted")
+			}
+		})
+	}
+
+	// store key is decrypted by the same passphrase
+	_, err = newEncoder(dir, Encoding{Passphrase: "secret"})
+	require.NoError(t, err)
+	_, err = newEncoder(dir, Encoding{Passphrase: "wrong"})
+	assert.Error(t, err)
+	_, err = newEncoder(dir, Encoding{Compression: "lz4"})
+	assert.Error(t, err)
+}
+
+func TestNewEncoder_concurrentKey(t *testing.T) {
+	dir := t.TempDir()
+	encoders := make([]encoder, 4)
+	errs := make([]error, len(encoders))
+	var wg sync.WaitGroup
+	for i := range encoders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			encoders[i], errs[i] = newEncoder(dir, Encoding{Passphrase: "secret", CreateKey: true})
+		}()
+	}
+	wg.Wait()
+
+	// all imports of new store use the same key, so data written by any of them can be read
+	for i, enc := range encoders {
+		require.NoError(t, errs[i])
+		b, err := enc.encode([]byte("data"))
+		require.NoError(t, err)
+		out, err := encoders[0].decode(b)
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(out))
+	}
+}
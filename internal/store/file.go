@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/pete911/awf/internal/types"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 const (
@@ -19,50 +21,163 @@ const (
 	ec2NetworkInterfacesKey = "ec2.describe-network-interfaces"
 )
 
+// FileOptions configures file store. Dir is the store directory, default is awf directory (see Dir). If Strict is
+// set, the first problem with stored data (e.g. missing account or resource file) fails the read, otherwise the problem
+// is skipped and recorded as warning. Encoding configures compression and encryption of written files.
+// ProfilePriority are glob patterns that select preferred profile of accounts, see types.Account PreferredProfile.
+// Wait makes reads and removals wait for running import, instead of returning LockedError (see RLock).
+type FileOptions struct {
+	Dir             string
+	Strict          bool
+	Encoding        Encoding
+	ProfilePriority []string
+	Wait            bool
+}
+
+var _ Store = File{}
+
 type File struct {
-	dir string
+	dir             string
+	enc             encoder
+	strict          bool
+	profilePriority []string
+	wait            bool
+	at              time.Time
+	warnings        *warnings
 }
 
-func LoadFile() (File, error) {
+// Dir returns awf directory, where imported data and configuration are stored. Default is $HOME/.awf, it can be
+// overridden by AWF_HOME env. variable.
+func Dir() (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return dir, nil
+	}
 	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rootDir), nil
+}
+
+func LoadFile(opts FileOptions) (File, error) {
+	dir := opts.Dir
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			return File{}, err
+		}
+	}
+	enc, err := newEncoder(dir, opts.Encoding)
 	if err != nil {
 		return File{}, err
 	}
 
 	return File{
-		dir: filepath.Join(home, rootDir),
+		dir:             dir,
+		enc:             enc,
+		strict:          opts.Strict,
+		profilePriority: opts.ProfilePriority,
+		wait:            opts.Wait,
+		warnings:        &warnings{},
 	}, nil
 }
 
-func initFile(account types.Account, region string) (File, error) {
-	f, err := LoadFile()
+// WriteAccount writes account metadata (_account file) to the account directory, profiles and roles are merged with
+// the stored account (see types.Account Merge). Store directory is readable only by the owner.
+func (f File) WriteAccount(account types.Account) error {
+	if err := os.MkdirAll(filepath.Join(f.dir, account.Id), 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.dir, 0700); err != nil {
+		return err
+	}
+
+	unlock, err := lock(lockPath(f.dir, account.Id))
 	if err != nil {
-		return File{}, err
+		return err
+	}
+	defer unlock()
+	var stored types.Account
+	if err := f.read(f.filePath(account.Id, "", accountFile), &stored); err == nil {
+		account = stored.Merge(account)
+	}
+	if err := f.write(account, "", accountFile, account); err != nil {
+		return fmt.Errorf("write account data: %w", err)
 	}
+	return nil
+}
 
-	if err := os.MkdirAll(filepath.Join(f.dir, account.Id, region), 0755); err != nil {
-		return File{}, err
+// NewRegionWriter starts new generation of the account region, see generation
+func (f File) NewRegionWriter(account types.Account, region string, retention Retention) (RegionWriter, error) {
+	gen, err := f.newGeneration(account.Id, region, retention)
+	if err != nil {
+		return nil, err
 	}
+	return gen, nil
+}
 
-	if err := f.write(account, "", accountFile, account); err != nil {
-		return File{}, fmt.Errorf("write account data: %w", err)
+// At returns store that reads snapshots that were current at supplied time. Regions that were not imported yet at
+// that time are skipped. Zero time reads current import.
+func (f File) At(t time.Time) Store {
+	f.at = t
+	return f
+}
+
+// ListSnapshots returns import times of stored snapshots of the region, oldest first
+func (f File) ListSnapshots(account types.Account, region string) ([]time.Time, error) {
+	return listGenerations(filepath.Join(f.dir, account.Id, region))
+}
+
+// Snapshot returns import time of the snapshot of the region that is read by the store (see At), false if the region
+// has no snapshot (it was not imported yet at that time, or it was imported before snapshots were introduced)
+func (f File) Snapshot(account types.Account, region string) (time.Time, bool) {
+	generationDir, ok := generationAt(filepath.Join(f.dir, account.Id, region), f.at)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(generationFormat, filepath.Base(generationDir))
+	if err != nil {
+		return time.Time{}, false
 	}
-	return f, nil
+	return t, true
 }
 
+// Warnings returns problems with stored data that were skipped by reads, it is always empty in strict mode
+func (f File) Warnings() []string {
+	return f.warnings.list()
+}
+
+// skip records the problem as warning and returns nil, in strict mode the problem is returned
+func (f File) skip(err error) error {
+	if f.strict {
+		return err
+	}
+	f.warnings.add(err)
+	return nil
+}
+
+// ListAccounts returns imported accounts. If the store does not exist, NotFoundError is returned.
 func (f File) ListAccounts() (types.Accounts, error) {
 	entry, err := os.ReadDir(f.dir)
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, NewNotFoundError(fmt.Sprintf("read: %s directory does not exist, run 'awf import' first", f.dir))
+		}
 		return nil, err
 	}
 
 	var accounts []types.Account
 	for _, e := range entry {
-		if e.IsDir() {
+		// workspaces directory has named workspaces (stores), see WorkspaceDir
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") && e.Name() != workspacesDir {
 			var account types.Account
 			if err := f.read(filepath.Join(f.dir, e.Name(), accountFile), &account); err != nil {
-				return nil, err
+				if err := f.skip(fmt.Errorf("account %s: %w", e.Name(), err)); err != nil {
+					return nil, err
+				}
+				continue
 			}
+			account.Profile = account.PreferredProfile(f.profilePriority)
 			accounts = append(accounts, account)
 		}
 	}
@@ -77,100 +192,323 @@ func (f File) ListRegions(account types.Account) ([]string, error) {
 
 	var regions []string
 	for _, e := range entry {
-		if e.IsDir() {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
 			regions = append(regions, e.Name())
 		}
 	}
 	return regions, nil
 }
 
+// ListResources returns metadata (import time and number of items) of resources imported in the region
+func (f File) ListResources(account types.Account, region string) ([]Resource, error) {
+	generationDir, ok := generationAt(filepath.Join(f.dir, account.Id, region), f.at)
+	if !ok {
+		return nil, nil
+	}
+	resources, err := readResources(f.enc, generationDir)
+	if err != nil {
+		return nil, f.skip(fmt.Errorf("account %s region %s: %w", account.Id, region, err))
+	}
+	return resources, nil
+}
+
+// ImportedAt returns import time of the oldest resource in the region, zero time if the region has no resources
+func (f File) ImportedAt(account types.Account, region string) (time.Time, error) {
+	resources, err := f.ListResources(account, region)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var importedAt time.Time
+	for _, v := range resources {
+		if importedAt.IsZero() || v.ImportedAt.Before(importedAt) {
+			importedAt = v.ImportedAt
+		}
+	}
+	return importedAt, nil
+}
+
 // DescribeNetworkInterfaces returns network interfaces. If the file is not found,
 // NotFoundError is returned. Meaning that the user need to run import first.
 func (f File) DescribeNetworkInterfaces() (types.NetworkInterfaces, error) {
-	accounts, err := f.ListAccounts()
+	var networkInterfaces types.NetworkInterfaces
+	err := f.forEachRegion(func(account types.Account, region string) error {
+		nis, err := readGenerationResource(f, networkInterfaceCodec, f.generationDir(account.Id, region), ec2NetworkInterfacesKey, account, region)
+		if err != nil {
+			return err
+		}
+		networkInterfaces = append(networkInterfaces, nis...)
+		return nil
+	})
+	return networkInterfaces, err
+}
+
+// DescribeVpcs returns VPCs. If the file is not found,
+// NotFoundError is returned. Meaning that the user need to run import first.
+func (f File) DescribeVpcs() (types.Vpcs, error) {
+	var vpcs types.Vpcs
+	err := f.forEachRegion(func(account types.Account, region string) error {
+		regionVpcs, err := readGenerationResource(f, vpcCodec, f.generationDir(account.Id, region), ec2VpcsKey, account, region)
+		if err != nil {
+			return err
+		}
+		vpcs = append(vpcs, regionVpcs...)
+		return nil
+	})
+	return vpcs, err
+}
+
+// DescribeSubnets returns subnets. If the file is not found,
+// NotFoundError is returned. Meaning that the user need to run import first.
+func (f File) DescribeSubnets() (types.Subnets, error) {
+	var subnets types.Subnets
+	err := f.forEachRegion(func(account types.Account, region string) error {
+		regionSubnets, err := readGenerationResource(f, subnetCodec, f.generationDir(account.Id, region), ec2SubnetsKey, account, region)
+		if err != nil {
+			return err
+		}
+		subnets = append(subnets, regionSubnets...)
+		return nil
+	})
+	return subnets, err
+}
+
+// GetNetworkInterfacesByIp returns network interfaces with the IP, see Lookup
+func (f File) GetNetworkInterfacesByIp(ip string) (types.NetworkInterfaces, error) {
+	addr, err := netip.ParseAddr(ip)
 	if err != nil {
 		return nil, err
 	}
+	result, err := f.Lookup(netip.PrefixFrom(addr, addr.BitLen()))
+	if err != nil {
+		return nil, err
+	}
+	return result.NetworkInterfaces.GetByIp(ip), nil
+}
 
-	var networkInterfaces types.NetworkInterfaces
-	for _, account := range accounts {
-		regions, err := f.ListRegions(account)
+// Lookup returns resources with CIDR or IP that overlaps the prefix. Generation index is used to find matched
+// resources, so only resource files with matches are read. Generations imported before indexes were introduced are
+// searched by reading all resources.
+func (f File) Lookup(prefix netip.Prefix) (LookupResult, error) {
+	var result LookupResult
+	err := f.forEachRegion(func(account types.Account, region string) error {
+		generationDir, _ := generationAt(filepath.Join(f.dir, account.Id, region), f.at)
+		idx, err := readIndex(f.enc, generationDir)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("account %s region %s: %w", account.Id, region, err)
 		}
-		for _, region := range regions {
-			path := f.filePath(account.Id, region, ec2NetworkInterfacesKey)
 
-			var nis []ec2types.NetworkInterface
-			if err := f.read(path, &nis); err != nil {
-				return nil, err
+		var ids map[string]map[string]bool
+		if idx != nil {
+			ids = lookupIds(idx, prefix)
+		}
+		// every resource is read if there is no index
+		read := func(key string) bool { return idx == nil || len(ids[key]) > 0 }
+
+		var regionResult LookupResult
+		if read(vpcIndexKey) {
+			vpcs, err := readGenerationResource(f, vpcCodec, generationDir, ec2VpcsKey, account, region)
+			if err != nil {
+				return err
+			}
+			regionResult.Vpcs = types.Vpcs(vpcs).GetByCidr(prefix.String())
+		}
+		if read(subnetIndexKey) {
+			subnets, err := readGenerationResource(f, subnetCodec, generationDir, ec2SubnetsKey, account, region)
+			if err != nil {
+				return err
 			}
-			networkInterfaces = append(networkInterfaces, types.ToNetworkInterfaces(account, region, nis)...)
+			regionResult.Subnets = types.Subnets(subnets).GetByCidr(prefix.String())
 		}
+		if read(networkInterfaceIndexKey) {
+			nis, err := readGenerationResource(f, networkInterfaceCodec, generationDir, ec2NetworkInterfacesKey, account, region)
+			if err != nil {
+				return err
+			}
+			if idx != nil {
+				nis = filterById(nis, ids[networkInterfaceIndexKey], func(v types.NetworkInterface) string { return v.NetworkInterfaceId })
+			}
+			regionResult.NetworkInterfaces = types.NetworkInterfaces(nis).GetByCidr(prefix.String())
+		}
+		result.add(regionResult)
+		return nil
+	})
+	return result, err
+}
+
+// GetNetworkInterfacesById returns network interfaces with supplied IDs, all network interfaces are read once
+func (f File) GetNetworkInterfacesById(ids ...string) (types.NetworkInterfaces, error) {
+	nis, err := f.DescribeNetworkInterfaces()
+	if err != nil {
+		return nil, err
 	}
-	return networkInterfaces, nil
+	return filterById(nis, idSet(ids), func(v types.NetworkInterface) string { return v.NetworkInterfaceId }), nil
 }
 
-// DescribeVpcs returns VPCs. If the file is not found,
-// NotFoundError is returned. Meaning that the user need to run import first.
-func (f File) DescribeVpcs() (types.Vpcs, error) {
-	accounts, err := f.ListAccounts()
+// GetVpcsById returns VPCs with supplied IDs, all VPCs are read once
+func (f File) GetVpcsById(ids ...string) (types.Vpcs, error) {
+	vpcs, err := f.DescribeVpcs()
 	if err != nil {
 		return nil, err
 	}
+	return filterById(vpcs, idSet(ids), func(v types.Vpc) string { return v.VpcId }), nil
+}
 
-	var vpcs types.Vpcs
-	for _, account := range accounts {
-		regions, err := f.ListRegions(account)
+// GetSubnetsById returns subnets with supplied IDs, all subnets are read once
+func (f File) GetSubnetsById(ids ...string) (types.Subnets, error) {
+	subnets, err := f.DescribeSubnets()
+	if err != nil {
+		return nil, err
+	}
+	return filterById(subnets, idSet(ids), func(v types.Subnet) string { return v.SubnetId }), nil
+}
+
+// Related returns VPCs of the subnets, subnets in the VPCs and network interfaces in the VPCs or subnets. Only
+// generations of account regions of supplied resources are read.
+func (f File) Related(vpcs types.Vpcs, subnets types.Subnets) (LookupResult, error) {
+	var result LookupResult
+	for _, ids := range groupRelated(vpcs, subnets) {
+		generationDir, ok := generationAt(filepath.Join(f.dir, ids.account.Id, ids.region), f.at)
+		if !ok {
+			continue
+		}
+		regionResult, err := f.readRelated(ids, generationDir)
 		if err != nil {
-			return nil, err
+			if err := f.skip(fmt.Errorf("account %s region %s: %w", ids.account.Id, ids.region, err)); err != nil {
+				return LookupResult{}, err
+			}
+			continue
 		}
-		for _, region := range regions {
-			path := f.filePath(account.Id, region, ec2VpcsKey)
+		result.add(regionResult)
+	}
+	return result, nil
+}
 
-			var awsVpcs []ec2types.Vpc
-			if err := f.read(path, &awsVpcs); err != nil {
-				return nil, err
-			}
-			vpcs = append(vpcs, types.ToVpcs(account, region, awsVpcs)...)
+func (f File) readRelated(ids *relatedIds, generationDir string) (LookupResult, error) {
+	var (
+		vpcs    []types.Vpc
+		subnets []types.Subnet
+		err     error
+	)
+	if len(ids.subnetVpcIds) != 0 {
+		if vpcs, err = readGenerationResource(f, vpcCodec, generationDir, ec2VpcsKey, ids.account, ids.region); err != nil {
+			return LookupResult{}, err
+		}
+	}
+	if len(ids.vpcIds) != 0 {
+		if subnets, err = readGenerationResource(f, subnetCodec, generationDir, ec2SubnetsKey, ids.account, ids.region); err != nil {
+			return LookupResult{}, err
 		}
 	}
-	return vpcs, nil
+	nis, err := readGenerationResource(f, networkInterfaceCodec, generationDir, ec2NetworkInterfacesKey, ids.account, ids.region)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	return ids.related(vpcs, subnets, nis), nil
 }
 
-// DescribeSubnets returns subnets. If the file is not found,
-// NotFoundError is returned. Meaning that the user need to run import first.
-func (f File) DescribeSubnets() (types.Subnets, error) {
+// WalkNetworkInterfaces calls fn with network interfaces of every stored snapshot, snapshots of a region are walked
+// oldest first. Regions imported before snapshots were introduced have only the current import.
+func (f File) WalkNetworkInterfaces(fn func(account types.Account, region string, snapshot time.Time, nis types.NetworkInterfaces)) error {
+	return f.forEachRegion(func(account types.Account, region string) error {
+		regionDir := filepath.Join(f.dir, account.Id, region)
+		snapshots, err := listGenerations(regionDir)
+		if err != nil {
+			return err
+		}
+
+		if len(snapshots) == 0 {
+			importedAt, err := f.ImportedAt(account, region)
+			if err != nil {
+				return err
+			}
+			nis, err := readGenerationResource(f, networkInterfaceCodec, currentGeneration(regionDir), ec2NetworkInterfacesKey, account, region)
+			if err != nil {
+				return err
+			}
+			fn(account, region, importedAt, nis)
+			return nil
+		}
+
+		for _, snapshot := range snapshots {
+			generationDir := filepath.Join(regionDir, snapshot.Format(generationFormat))
+			nis, err := readGenerationResource(f, networkInterfaceCodec, generationDir, ec2NetworkInterfacesKey, account, region)
+			if err != nil {
+				if err := f.skip(fmt.Errorf("account %s region %s: %w", account.Id, region, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			fn(account, region, snapshot, nis)
+		}
+		return nil
+	})
+}
+
+// forEachRegion calls fn for every imported account and region. Errors returned by fn are skipped (recorded as
+// warnings), unless the store is in strict mode.
+func (f File) forEachRegion(fn func(account types.Account, region string) error) error {
 	accounts, err := f.ListAccounts()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var subnets types.Subnets
 	for _, account := range accounts {
 		regions, err := f.ListRegions(account)
 		if err != nil {
-			return nil, err
+			if err := f.skip(fmt.Errorf("account %s: %w", account.Id, err)); err != nil {
+				return err
+			}
+			continue
 		}
 		for _, region := range regions {
-			path := f.filePath(account.Id, region, ec2SubnetsKey)
-
-			var awsSubnets []ec2types.Subnet
-			if err := f.read(path, &awsSubnets); err != nil {
-				return nil, err
+			if _, ok := generationAt(filepath.Join(f.dir, account.Id, region), f.at); !ok {
+				continue
+			}
+			if err := fn(account, region); err != nil {
+				if err := f.skip(err); err != nil {
+					return err
+				}
 			}
-			subnets = append(subnets, types.ToSubnets(account, region, awsSubnets)...)
 		}
 	}
-	return subnets, nil
+	return nil
 }
 
-func (f File) read(path string, v any) error {
-	b, err := os.ReadFile(path)
+// readGenerationResource reads resource file of the generation, resources are decoded according to generation schema
+// version (see SchemaVersion)
+func readGenerationResource[T, S any](f File, c codec[T, S], generationDir, name string, account types.Account, region string) ([]T, error) {
+	version, err := readSchemaVersion(f.enc, generationDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", generationDir, err)
+	}
+	path := filepath.Join(generationDir, name)
+	b, err := f.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.decode(version, b, account, region)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// readFile reads and decodes the file, see Encoding
+func (f File) readFile(path string) ([]byte, error) {
+	b, err := f.enc.readFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return NewNotFoundError(fmt.Sprintf("read: %s file does not exist, empty content", path))
+			return nil, NewNotFoundError(fmt.Sprintf("read: %s file does not exist, empty content", path))
 		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (f File) read(path string, v any) error {
+	b, err := f.readFile(path)
+	if err != nil {
 		return err
 	}
 
@@ -181,19 +519,113 @@ func (f File) read(path string, v any) error {
 }
 
 // Write writes content of the supplied (json) struct under supplied <name> file. Region
-// can be empty (e.g. route53)
+// can be empty (e.g. route53). Region resources are written by generation, see newGeneration.
 func (f File) write(account types.Account, region, name string, v any) error {
-	path := f.filePath(account.Id, region, name)
-	b, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal %s: %w", path, err)
-	}
-	return os.WriteFile(path, b, 0644)
+	return f.enc.writeJson(f.filePath(account.Id, region, name), v)
 }
 
+// filePath returns path of the file in the current generation (or generation current at the time set by At), or in
+// the account directory if region is empty
 func (f File) filePath(accountId, region, name string) string {
 	if region == "" {
 		return filepath.Join(f.dir, accountId, name)
 	}
-	return filepath.Join(f.dir, accountId, region, name)
+	return filepath.Join(f.generationDir(accountId, region), name)
+}
+
+// generationDir returns directory of the current generation (or generation current at the time set by At), region
+// directory is returned if the region has no generation at that time
+func (f File) generationDir(accountId, region string) string {
+	regionDir := filepath.Join(f.dir, accountId, region)
+	if generationDir, ok := generationAt(regionDir, f.at); ok {
+		return generationDir
+	}
+	return regionDir
+}
+
+// writeFileAtomic writes content to temporary file and renames it, readers never see partially written file. File is
+// readable only by the owner.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RemoveAccount removes account directory, the account and its regions are locked, so running import is not removed
+func (f File) RemoveAccount(account types.Account) error {
+	regions, err := f.ListRegions(account)
+	if err != nil {
+		return err
+	}
+	unlock, err := f.lockRemoval(account.Id, regions...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.RemoveAll(filepath.Join(f.dir, account.Id)); err != nil {
+		return fmt.Errorf("remove account %s: %w", account.Id, err)
+	}
+	return nil
+}
+
+// RemoveRegion removes region directory with all generations (snapshots)
+func (f File) RemoveRegion(account types.Account, region string) error {
+	unlock, err := f.lockRemoval(account.Id, region)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.RemoveAll(filepath.Join(f.dir, account.Id, region)); err != nil {
+		return fmt.Errorf("remove account %s region %s: %w", account.Id, region, err)
+	}
+	return nil
+}
+
+// RemoveSnapshots removes generations imported at supplied times, current generation is kept
+func (f File) RemoveSnapshots(account types.Account, region string, snapshots []time.Time) error {
+	unlock, err := f.lockRemoval(account.Id, region)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	regionDir := filepath.Join(f.dir, account.Id, region)
+	current := filepath.Base(currentGeneration(regionDir))
+	var errs []error
+	for _, t := range snapshots {
+		name := t.UTC().Format(generationFormat)
+		if name == current {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(regionDir, name)); err != nil {
+			errs = append(errs, fmt.Errorf("remove account %s region %s snapshot %s: %w", account.Id, region, name, err))
+		}
+	}
+	// region is locked, staging directories are left by imports that did not finish
+	if err := removeStaging(regionDir); err != nil {
+		errs = append(errs, fmt.Errorf("account %s region %s: %w", account.Id, region, err))
+	}
+	return errors.Join(errs...)
+}
+
+// lockRemoval locks the account, its regions and the whole store before RemoveAccount, RemoveRegion or
+// RemoveSnapshots change them
+func (f File) lockRemoval(accountId string, regions ...string) (func() error, error) {
+	return lockRemoval(f.dir, f.wait, accountId, regions...)
 }
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFile_skipBrokenData(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-1").Commit())
+	// directory without account file and region without subnets file
+	require.NoError(t, os.MkdirAll(filepath.Join(f.dir, "stray"), 0755))
+	gen := writeTestGeneration(t, f, account.Id, "eu-west-1", "vpc-2")
+	require.NoError(t, os.Remove(filepath.Join(gen.stagingDir, ec2SubnetsKey)))
+	require.NoError(t, gen.Commit())
+
+	f.strict = false
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vpc-1", "vpc-2"}, vpcIds(vpcs))
+	_, err = f.DescribeSubnets()
+	require.NoError(t, err)
+	assert.Len(t, f.Warnings(), 2)
+
+	f.strict = true
+	_, err = f.DescribeVpcs()
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestFile_ListAccounts_notInitialized(t *testing.T) {
+	f := File{dir: filepath.Join(t.TempDir(), "missing"), warnings: &warnings{}}
+
+	_, err := f.ListAccounts()
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestFile_Lookup(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	gen, err := f.newGeneration(account.Id, "eu-west-2", Retention{})
+	require.NoError(t, err)
+	require.NoError(t, gen.Write(ec2VpcsKey, types.Vpcs{{VpcId: "vpc-1", CidrBlock: "10.0.0.0/16"}}, 1))
+	require.NoError(t, gen.Write(ec2SubnetsKey, types.Subnets{
+		{SubnetId: "subnet-1", CidrBlock: "10.0.1.0/24"},
+		{SubnetId: "subnet-2", CidrBlock: "10.0.2.0/24"},
+	}, 2))
+	require.NoError(t, gen.Write(ec2NetworkInterfacesKey, types.NetworkInterfaces{
+		{NetworkInterfaceId: "eni-1", PrivateIpAddress: "10.0.1.10"},
+		{NetworkInterfaceId: "eni-2", PrivateIpAddress: "10.0.2.10"},
+	}, 2))
+	require.NoError(t, gen.Commit())
+
+	result, err := f.Lookup(netip.MustParsePrefix("10.0.1.0/25"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(result.Vpcs))
+	require.Len(t, result.Subnets, 1)
+	assert.Equal(t, "subnet-1", result.Subnets[0].SubnetId)
+	require.Len(t, result.NetworkInterfaces, 1)
+	assert.Equal(t, "eni-1", result.NetworkInterfaces[0].NetworkInterfaceId)
+
+	// generation without index (imported before indexes) is searched by reading all resources
+	require.NoError(t, os.Remove(filepath.Join(currentGeneration(filepath.Join(f.dir, account.Id, "eu-west-2")), indexFile)))
+	nis, err := f.GetNetworkInterfacesByIp("10.0.2.10")
+	require.NoError(t, err)
+	require.Len(t, nis, 1)
+	assert.Equal(t, "eni-2", nis[0].NetworkInterfaceId)
+
+	nis, err = f.GetNetworkInterfacesById("eni-1", "eni-2", "eni-3")
+	require.NoError(t, err)
+	assert.Len(t, nis, 2)
+	counts, err := CountItems(f)
+	require.NoError(t, err)
+	assert.Equal(t, ItemCounts{Vpcs: 1, Subnets: 2, NetworkInterfaces: 2}, counts)
+}
+
+func TestFile_Related(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	gen, err := f.newGeneration(account.Id, "eu-west-2", Retention{})
+	require.NoError(t, err)
+	writeRelatedTestResources(t, gen)
+	testRelated(t, f)
+}
+
+func BenchmarkFile_Lookup(b *testing.B) {
+	account := types.Account{Id: "123456789012"}
+	f := File{dir: b.TempDir(), strict: true, warnings: &warnings{}}
+	require.NoError(b, f.WriteAccount(account))
+	gen, err := f.newGeneration(account.Id, "eu-west-2", Retention{})
+	require.NoError(b, err)
+	writeBenchmarkResources(b, gen)
+	benchmarkLookup(b, f)
+}
+
+func TestFile_schemaVersion(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	// aws sdk schema (generation without schema file) is converted when read
+	writeTestSnapshot(t, f, account.Id, "eu-west-1", time.Now().Add(-time.Hour), "vpc-1")
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-2").Commit())
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1", "vpc-2"}, vpcIds(vpcs))
+	assert.Equal(t, "eu-west-2", vpcs[1].Region)
+
+	// generation written by newer awf is refused
+	generationDir := currentGeneration(filepath.Join(f.dir, account.Id, "eu-west-2"))
+	require.NoError(t, f.enc.writeJson(filepath.Join(generationDir, schemaFile), schema{Version: SchemaVersion + 1}))
+	_, err = f.DescribeVpcs()
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Contains(t, err.Error(), "upgrade awf")
+}
+
+func TestFile_WriteAccount(t *testing.T) {
+	f := File{dir: t.TempDir(), strict: true, warnings: &warnings{}}
+	require.NoError(t, f.WriteAccount(types.Account{Id: "123456789012", Profile: "prod-admin", Profiles: []string{"prod-admin"}}))
+	require.NoError(t, f.WriteAccount(types.Account{
+		Id:        "123456789012",
+		Profiles:  []string{"prod-readonly"},
+		Roles:     []string{"ReadOnly"},
+		Alias:     "prod",
+		Partition: "aws",
+	}))
+	// credentials without profile do not record blank profile
+	require.NoError(t, f.WriteAccount(types.Account{Id: "123456789012"}))
+
+	accounts, err := f.ListAccounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, []string{"prod-admin", "prod-readonly"}, accounts[0].Profiles)
+	assert.Equal(t, []string{"ReadOnly"}, accounts[0].Roles)
+	assert.Equal(t, "prod", accounts[0].Alias)
+	assert.Equal(t, "aws", accounts[0].Partition)
+	assert.Equal(t, "prod-admin", accounts[0].Profile)
+
+	f.profilePriority = []string{"*-readonly", "*-admin"}
+	accounts, err = f.ListAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, "prod-readonly", accounts[0].Profile)
+}
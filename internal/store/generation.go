@@ -0,0 +1,361 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/internal/index"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	currentFile      = "current"
+	metaFile         = "_meta"
+	stagingPrefix    = ".staging-"
+	generationFormat = "20060102T150405.000Z"
+)
+
+var resourceKeys = []string{ec2VpcsKey, ec2SubnetsKey, ec2NetworkInterfacesKey}
+
+// generation is one import of account and region. Files are written to staging directory and the whole directory
+// is swapped in by commit (current file points to the committed generation), so readers see either the previous
+// or the new import, never partial one. Committed generations are kept as snapshots (import history) according to
+// retention. Region directory layout:
+//
+//	<region>/current              - name of the current generation
+//	<region>/<generation>/...     - committed generation, name is import (UTC) time
+//	<region>/<generation>/_meta   - import time and number of items of every resource in the generation
+//	<region>/<generation>/_index  - prefix index of CIDRs and IPs of resources in the generation
+//	<region>/<generation>/_schema - schema version of resources in the generation, see SchemaVersion
+//	<region>/<generation>/_raw/   - raw aws api responses, only if import was run with raw flag
+//	<region>/.staging-<random>/   - generation that is being imported
+type generation struct {
+	enc        encoder
+	storeDir   string
+	regionDir  string
+	stagingDir string
+	retention  Retention
+	// importedAt is set when existing snapshot is copied, otherwise commit time is used
+	importedAt time.Time
+	// unlock releases region lock, that is held until commit or discard
+	unlock func() error
+
+	mu        sync.Mutex
+	resources []Resource
+	index     *index.Trie
+}
+
+// Retention configures how many snapshots (committed generations) of a region are kept, zero values are unlimited.
+// Current generation is always kept.
+type Retention struct {
+	Count  int
+	MaxAge time.Duration
+}
+
+// Resource is metadata of imported resource (file)
+type Resource struct {
+	Name       string    `json:"name"`
+	ImportedAt time.Time `json:"imported_at"`
+	Items      int       `json:"items"`
+}
+
+// newGeneration starts new generation, concurrent imports of the same region (e.g. by another awf process) wait for
+// each other, see lockPath
+func (f File) newGeneration(accountId, region string, retention Retention) (*generation, error) {
+	regionDir := filepath.Join(f.dir, accountId, region)
+	if err := os.MkdirAll(regionDir, 0700); err != nil {
+		return nil, err
+	}
+	unlock, err := lock(lockPath(f.dir, accountId, region))
+	if err != nil {
+		return nil, err
+	}
+
+	// region is locked, so staging directories left in the region are from imports that did not finish (e.g. killed)
+	if err := removeStaging(regionDir); err != nil {
+		return nil, errors.Join(err, unlock())
+	}
+	stagingDir, err := os.MkdirTemp(regionDir, stagingPrefix)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("create staging generation: %w", err), unlock())
+	}
+	return &generation{
+		enc:        f.enc,
+		storeDir:   f.dir,
+		regionDir:  regionDir,
+		stagingDir: stagingDir,
+		retention:  retention,
+		unlock:     unlock,
+		index:      index.New(),
+	}, nil
+}
+
+// Write writes resource file with supplied number of items, it is safe to call write concurrently
+func (g *generation) Write(name string, v any, items int) error {
+	if err := g.enc.writeJson(filepath.Join(g.stagingDir, name), v); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.resources = append(g.resources, Resource{Name: name, ImportedAt: time.Now().UTC(), Items: items})
+	indexResources(g.index, v)
+	return nil
+}
+
+// WriteRaw writes raw aws api response to the generation _raw directory
+func (g *generation) WriteRaw(name string, v any) error {
+	if err := os.MkdirAll(filepath.Join(g.stagingDir, rawDir), 0700); err != nil {
+		return err
+	}
+	return g.enc.writeJson(filepath.Join(g.stagingDir, rawDir, name), v)
+}
+
+// Commit moves staging directory to new generation, points current file to it and removes snapshots that are out of
+// retention. Generation is swapped under exclusive store lock, so it does not change data that are being read. If
+// commit fails, staging directory is removed and previous generation is kept.
+func (g *generation) Commit() error {
+	defer g.unlock()
+	if err := g.commit(); err != nil {
+		// staging directory does not exist if commit failed after it was moved to the new generation
+		return errors.Join(err, os.RemoveAll(g.stagingDir))
+	}
+	return nil
+}
+
+func (g *generation) commit() error {
+	g.mu.Lock()
+	resources := slices.Clone(g.resources)
+	idx, err := g.index.MarshalBinary()
+	g.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("write generation index: %w", err)
+	}
+	slices.SortFunc(resources, func(a, b Resource) int { return strings.Compare(a.Name, b.Name) })
+	if err := g.enc.writeJson(filepath.Join(g.stagingDir, metaFile), resources); err != nil {
+		return fmt.Errorf("write generation metadata: %w", err)
+	}
+	if err := g.enc.writeFile(filepath.Join(g.stagingDir, indexFile), idx); err != nil {
+		return fmt.Errorf("write generation index: %w", err)
+	}
+	if err := g.enc.writeJson(filepath.Join(g.stagingDir, schemaFile), schema{Version: SchemaVersion}); err != nil {
+		return fmt.Errorf("write generation schema: %w", err)
+	}
+
+	unlockStore, err := lock(lockPath(g.storeDir, storeLock))
+	if err != nil {
+		return err
+	}
+	defer unlockStore()
+
+	importedAt := g.importedAt
+	if importedAt.IsZero() {
+		importedAt = time.Now().UTC()
+	}
+	name := newGenerationName(g.regionDir, importedAt)
+	if err := os.Rename(g.stagingDir, filepath.Join(g.regionDir, name)); err != nil {
+		return fmt.Errorf("commit generation %s: %w", name, err)
+	}
+	current, err := latestGeneration(g.regionDir, name)
+	if err != nil {
+		return fmt.Errorf("commit generation %s: %w", name, err)
+	}
+	if err := writeFileAtomic(filepath.Join(g.regionDir, currentFile), []byte(current)); err != nil {
+		return fmt.Errorf("commit generation %s: %w", name, err)
+	}
+	return removeOldGenerations(g.regionDir, current, g.retention)
+}
+
+// keepImportTime sets import time of the generation and metadata of its resources, copied snapshot that is older than
+// the current generation is added to the history and current generation is kept
+func (g *generation) keepImportTime(importedAt time.Time, resources []Resource) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.importedAt = importedAt.UTC()
+	g.resources = slices.Clone(resources)
+}
+
+// latestGeneration returns name of the newest committed generation, committed is returned if the region has no other
+// generation
+func latestGeneration(regionDir, committed string) (string, error) {
+	snapshots, err := listGenerations(regionDir)
+	if err != nil {
+		return "", err
+	}
+	if len(snapshots) == 0 {
+		return committed, nil
+	}
+	return snapshots[len(snapshots)-1].Format(generationFormat), nil
+}
+
+// newGenerationName returns generation name from the import time, time is moved if another import of the region
+// was committed in the same millisecond
+func newGenerationName(regionDir string, t time.Time) string {
+	for {
+		name := t.Format(generationFormat)
+		if _, err := os.Stat(filepath.Join(regionDir, name)); err != nil {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// Discard removes staging directory, current generation is kept
+func (g *generation) Discard() error {
+	defer g.unlock()
+	if err := os.RemoveAll(g.stagingDir); err != nil {
+		return err
+	}
+	// region directory is left only if it has previous imports, error means that the directory is not empty
+	os.Remove(g.regionDir)
+	return nil
+}
+
+// listStaging returns staging directories in the region directory
+func listStaging(regionDir string) ([]string, error) {
+	entries, err := os.ReadDir(regionDir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), stagingPrefix) {
+			out = append(out, filepath.Join(regionDir, e.Name()))
+		}
+	}
+	return out, nil
+}
+
+// removeStaging removes staging directories in the region directory, region has to be locked
+func removeStaging(regionDir string) error {
+	dirs, err := listStaging(regionDir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, fmt.Errorf("remove unfinished import %s: %w", dir, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// currentGeneration returns directory of the current generation. Region directories imported before generations
+// were introduced do not have current file, files are directly in the region directory.
+func currentGeneration(regionDir string) string {
+	b, err := os.ReadFile(filepath.Join(regionDir, currentFile))
+	if err != nil {
+		return regionDir
+	}
+	return filepath.Join(regionDir, strings.TrimSpace(string(b)))
+}
+
+// generationAt returns directory of the generation that was current at supplied time, false if the region was not
+// imported yet at that time. Zero time returns current generation.
+func generationAt(regionDir string, at time.Time) (string, bool) {
+	if at.IsZero() {
+		return currentGeneration(regionDir), true
+	}
+
+	snapshots, err := listGenerations(regionDir)
+	if err != nil {
+		return "", false
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].After(at) {
+			return filepath.Join(regionDir, snapshots[i].UTC().Format(generationFormat)), true
+		}
+	}
+	return "", false
+}
+
+// listGenerations returns import times of committed generations (snapshots), oldest first
+func listGenerations(regionDir string) ([]time.Time, error) {
+	entries, err := os.ReadDir(regionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []time.Time
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if t, err := time.Parse(generationFormat, e.Name()); err == nil {
+			out = append(out, t)
+		}
+	}
+	slices.SortFunc(out, func(a, b time.Time) int { return a.Compare(b) })
+	return out, nil
+}
+
+// readResources returns metadata of resources in the generation directory. Generations imported before metadata
+// were introduced use modification time of the resource file as import time, number of items is unknown (-1).
+func readResources(enc encoder, generationDir string) ([]Resource, error) {
+	b, err := enc.readFile(filepath.Join(generationDir, metaFile))
+	if err == nil {
+		var resources []Resource
+		if err := json.Unmarshal(b, &resources); err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %w", filepath.Join(generationDir, metaFile), err)
+		}
+		return resources, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var resources []Resource
+	for _, key := range resourceKeys {
+		info, err := os.Stat(filepath.Join(generationDir, key))
+		if err != nil {
+			continue
+		}
+		resources = append(resources, Resource{Name: key, ImportedAt: info.ModTime().UTC(), Items: -1})
+	}
+	return resources, nil
+}
+
+// removeOldGenerations removes snapshots that are out of retention and files imported before generations were
+// introduced. Current generation and staging directories (imports that are still running) are kept.
+func removeOldGenerations(regionDir, current string, retention Retention) error {
+	snapshots, err := listGenerations(regionDir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	currentTime, _ := time.Parse(generationFormat, current)
+	for _, t := range outOfRetention(snapshots, currentTime, retention) {
+		if err := os.RemoveAll(filepath.Join(regionDir, t.UTC().Format(generationFormat))); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, key := range resourceKeys {
+		if err := os.Remove(filepath.Join(regionDir, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// outOfRetention returns snapshots (import times, oldest first) that are out of retention, current snapshot is kept
+func outOfRetention(snapshots []time.Time, current time.Time, retention Retention) []time.Time {
+	var out []time.Time
+	for i, t := range snapshots {
+		if t.Equal(current) {
+			continue
+		}
+		newer := len(snapshots) - 1 - i
+		if (retention.Count > 0 && newer >= retention.Count) || (retention.MaxAge > 0 && time.Since(t) > retention.MaxAge) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
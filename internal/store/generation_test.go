@@ -0,0 +1,204 @@
+package store
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFile(t *testing.T, account types.Account) File {
+	f := File{dir: t.TempDir(), strict: true, warnings: &warnings{}}
+	require.NoError(t, os.MkdirAll(filepath.Join(f.dir, account.Id), 0755))
+	require.NoError(t, f.write(account, "", accountFile, account))
+	return f
+}
+
+func writeTestGeneration(t *testing.T, f File, accountId, region string, vpcIds ...string) *generation {
+	gen, err := f.newGeneration(accountId, region, Retention{})
+	require.NoError(t, err)
+
+	var vpcs types.Vpcs
+	for _, id := range vpcIds {
+		vpcs = append(vpcs, types.Vpc{VpcId: id})
+	}
+	require.NoError(t, gen.Write(ec2VpcsKey, vpcs, len(vpcs)))
+	require.NoError(t, gen.Write(ec2SubnetsKey, types.Subnets{}, 0))
+	require.NoError(t, gen.Write(ec2NetworkInterfacesKey, types.NetworkInterfaces{}, 0))
+	return gen
+}
+
+func TestGeneration(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-1").Commit())
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+
+	// failed import is discarded, previous generation is kept
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-2").Discard())
+	vpcs, err = f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-3").Commit())
+	vpcs, err = f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-3"}, vpcIds(vpcs))
+
+	// both committed generations are kept as snapshots, discarded one is removed
+	snapshots, err := f.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	entries, err := os.ReadDir(filepath.Join(f.dir, account.Id, "eu-west-2"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestGeneration_commitFailure(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-1").Commit())
+
+	// meta file cannot be written over directory
+	gen := writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-2")
+	require.NoError(t, os.Mkdir(filepath.Join(gen.stagingDir, metaFile), 0700))
+	require.Error(t, gen.Commit())
+	assert.NoDirExists(t, gen.stagingDir)
+
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+}
+
+func TestGeneration_staleStaging(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	stale := filepath.Join(f.dir, account.Id, "eu-west-2", stagingPrefix+"1")
+	require.NoError(t, os.MkdirAll(stale, 0700))
+
+	// next import of the region removes staging directory of import that did not finish
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-1").Commit())
+	assert.NoDirExists(t, stale)
+}
+
+func TestGeneration_retention(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	now := time.Now().UTC()
+	for _, days := range []int{100, 20, 10, 5} {
+		writeTestSnapshot(t, f, account.Id, "eu-west-2", now.Add(-time.Duration(days)*24*time.Hour))
+	}
+
+	gen, err := f.newGeneration(account.Id, "eu-west-2", Retention{Count: 3, MaxAge: 90 * 24 * time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, gen.Commit())
+
+	// snapshot older than 90 days and snapshot over count (20 days) are removed
+	snapshots, err := f.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3)
+	assert.WithinDuration(t, now.Add(-10*24*time.Hour), snapshots[0], time.Second)
+	assert.WithinDuration(t, now.Add(-5*24*time.Hour), snapshots[1], time.Second)
+}
+
+func TestFile_At(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	now := time.Now().UTC()
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", now.Add(-48*time.Hour), "vpc-1")
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", now.Add(-24*time.Hour), "vpc-2")
+	writeTestSnapshot(t, f, account.Id, "eu-west-1", now.Add(-time.Hour), "vpc-3")
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected []string
+	}{
+		{name: "current", expected: []string{"vpc-3", "vpc-2"}},
+		{name: "before first import", at: now.Add(-72 * time.Hour)},
+		{name: "first snapshot", at: now.Add(-36 * time.Hour), expected: []string{"vpc-1"}},
+		{name: "second snapshot", at: now.Add(-2 * time.Hour), expected: []string{"vpc-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vpcs, err := f.At(tt.at).DescribeVpcs()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, vpcIds(vpcs))
+		})
+	}
+}
+
+func TestFile_Snapshot(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	first := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", first, "vpc-1")
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", first.Add(24*time.Hour), "vpc-2")
+
+	snapshot, ok := f.At(first.Add(time.Hour)).Snapshot(account, "eu-west-2")
+	require.True(t, ok)
+	assert.Equal(t, first, snapshot)
+
+	snapshot, ok = f.Snapshot(account, "eu-west-2")
+	require.True(t, ok)
+	assert.Equal(t, first.Add(24*time.Hour), snapshot)
+
+	_, ok = f.At(first.Add(-time.Hour)).Snapshot(account, "eu-west-2")
+	assert.False(t, ok)
+}
+
+// writeTestSnapshot writes committed generation imported at supplied time and makes it current, resources are in
+// aws sdk schema version (generation without schema file)
+func writeTestSnapshot(t *testing.T, f File, accountId, region string, at time.Time, vpcIds ...string) {
+	regionDir := filepath.Join(f.dir, accountId, region)
+	name := at.UTC().Format(generationFormat)
+	require.NoError(t, os.MkdirAll(filepath.Join(regionDir, name), 0755))
+
+	var vpcs []ec2types.Vpc
+	for _, id := range vpcIds {
+		vpcs = append(vpcs, ec2types.Vpc{VpcId: aws.String(id)})
+	}
+	require.NoError(t, f.enc.writeJson(filepath.Join(regionDir, name, ec2VpcsKey), vpcs))
+	require.NoError(t, f.enc.writeJson(filepath.Join(regionDir, name, metaFile), []Resource{{Name: ec2VpcsKey, ImportedAt: at, Items: len(vpcs)}}))
+	require.NoError(t, writeFileAtomic(filepath.Join(regionDir, currentFile), []byte(name)))
+}
+
+func TestGeneration_legacyLayout(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+
+	// files written directly to region directory (before generations)
+	regionDir := filepath.Join(f.dir, account.Id, "eu-west-2")
+	require.NoError(t, os.MkdirAll(regionDir, 0755))
+	require.NoError(t, f.enc.writeJson(filepath.Join(regionDir, ec2VpcsKey), []ec2types.Vpc{{VpcId: aws.String("vpc-1")}}))
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+	// items of data imported before resource metadata are not counted
+	counts, err := CountItems(f)
+	require.NoError(t, err)
+	assert.Equal(t, ItemCounts{}, counts)
+
+	// commit replaces legacy files
+	require.NoError(t, writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-2").Commit())
+	vpcs, err = f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-2"}, vpcIds(vpcs))
+	assert.NoFileExists(t, filepath.Join(regionDir, ec2VpcsKey))
+}
+
+func vpcIds(vpcs types.Vpcs) []string {
+	var out []string
+	for _, v := range vpcs {
+		out = append(out, v.VpcId)
+	}
+	return out
+}
@@ -5,88 +5,256 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/pete911/awf/internal/types"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
-type importer func(account types.Account, cfg aws.Config, file File) error
+const (
+	allRegions    = "all"
+	defaultRegion = "us-east-1"
+	// defaultConfigTimeout limits loading of aws config if import options do not set timeout
+	defaultConfigTimeout = 5 * time.Second
+	// ssoRolePrefix is prefix of IAM roles created by IAM Identity Center (SSO) for permission sets
+	ssoRolePrefix = "AWSReservedSSO_"
+)
+
+const (
+	configStep  = "config"
+	accountStep = "account"
+	regionsStep = "ec2.describe-regions"
+	storeStep   = "store"
+)
+
+// importer imports resources of the account region, calls are aws api calls that the importer makes (see CheckAccess)
+type importer struct {
+	run   func(account types.Account, cfg aws.Config, w RegionWriter, opts ImportOptions) []ImportEntry
+	calls []apiCall
+}
 
 var importers = []importer{
-	ec2Import,
+	{run: ec2Import, calls: ec2Calls()},
+}
+
+// ImportOptions configures import. Regions can be empty (region from aws config is used), list of regions, or 'all'
+// for all enabled regions. EndpointUrl overrides aws endpoints (e.g. local moto server). Timeout applies to every
+// aws api call (page) and to loading of aws config, MaxRetries is the number of retries of failed (e.g. throttled)
+// calls. Imported data are written to Store, Raw stores raw aws api responses as well.
+type ImportOptions struct {
+	Regions     []string
+	EndpointUrl string
+	Workers     int
+	Timeout     time.Duration
+	MaxRetries  int
+	Retention   Retention
+	Raw         bool
+	Store       Store
+}
+
+// ImportProfiles imports supplied aws profiles concurrently, number of concurrent imports is limited by workers.
+func ImportProfiles(profiles []string, opts ImportOptions) ImportResult {
+	results := &importResults{}
+	runWorkers(len(profiles), opts.Workers, func(i int) {
+		importProfile(profiles[i], opts, results)
+	})
+	return results.result()
+}
+
+// Import imports aws resources for supplied profile. If the profile is empty, default aws config chain is used.
+func Import(profile string, opts ImportOptions) ImportResult {
+	results := &importResults{}
+	importProfile(profile, opts, results)
+	return results.result()
 }
 
-func Import(region string) error {
-	cfg, err := newAwsConfig(region)
+func importProfile(profile string, opts ImportOptions, results *importResults) {
+	cfg, err := newAwsConfig(profile, opts)
 	if err != nil {
-		return err
+		results.add(ImportEntry{Profile: profile, Importer: configStep, Err: err})
+		return
 	}
 
-	account, err := getCurrentAWSAccount(cfg)
+	start := time.Now()
+	account, err := getCurrentAWSAccount(cfg, profile, opts.Timeout)
 	if err != nil {
-		return err
+		results.add(ImportEntry{Profile: profile, Region: cfg.Region, Importer: accountStep, Duration: time.Since(start), Err: err})
+		return
 	}
-	file, err := initFile(account, cfg.Region)
+	importAccount(account, cfg, opts, results)
+}
+
+func importAccount(account types.Account, cfg aws.Config, opts ImportOptions, results *importResults) {
+	start := time.Now()
+	importRegions, err := resolveRegions(cfg, opts)
 	if err != nil {
-		return err
+		results.add(ImportEntry{
+			Profile:   account.Profile,
+			AccountId: account.Id,
+			Region:    cfg.Region,
+			Importer:  regionsStep,
+			Duration:  time.Since(start),
+			Err:       err,
+		})
+		return
 	}
 
-	var hasErrors bool
 	var wg sync.WaitGroup
-	for _, i := range importers {
+	for _, region := range importRegions {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := i(account, cfg, file); err != nil {
-				fmt.Println(err.Error())
-				hasErrors = true
+			regionCfg := cfg.Copy()
+			regionCfg.Region = region
+			results.add(importRegion(account, regionCfg, opts)...)
+		}()
+	}
+	wg.Wait()
+}
+
+// importRegion runs all importers for the region. Imported data are committed only if all importers succeeded,
+// otherwise previous import of the region is kept.
+func importRegion(account types.Account, cfg aws.Config, opts ImportOptions) []ImportEntry {
+	storeEntry := ImportEntry{Profile: account.Profile, AccountId: account.Id, Region: cfg.Region, Importer: storeStep}
+	if err := opts.Store.WriteAccount(account); err != nil {
+		storeEntry.Err = err
+		return []ImportEntry{storeEntry}
+	}
+	w, err := opts.Store.NewRegionWriter(account, cfg.Region, opts.Retention)
+	if err != nil {
+		storeEntry.Err = err
+		return []ImportEntry{storeEntry}
+	}
+
+	entries := make([][]ImportEntry, len(importers))
+	var wg sync.WaitGroup
+	for n, i := range importers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries[n] = i.run(account, cfg, w, opts)
+		}()
+	}
+	wg.Wait()
+	result := ImportResult(slices.Concat(entries...))
+
+	start := time.Now()
+	if result.Failed() > 0 {
+		storeEntry.Err = errors.New("region import failed, previous import is kept")
+		if err := w.Discard(); err != nil {
+			storeEntry.Err = fmt.Errorf("%w: discard: %w", storeEntry.Err, err)
+		}
+	} else {
+		storeEntry.Err = w.Commit()
+	}
+	storeEntry.Duration = time.Since(start)
+	return append(result, storeEntry)
+}
+
+// runWorkers calls fn for every index in [0, n) with at most workers concurrent calls
+func runWorkers(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
 			}
 		}()
 	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
 	wg.Wait()
-	if hasErrors {
-		return errors.New("import failed")
+}
+
+// resolveRegions returns regions to import. 'all' is resolved by calling ec2 describe regions.
+func resolveRegions(cfg aws.Config, opts ImportOptions) ([]string, error) {
+	if len(opts.Regions) == 0 {
+		return []string{cfg.Region}, nil
+	}
+	if slices.Contains(opts.Regions, allRegions) {
+		return describeRegions(cfg, opts.Timeout)
 	}
-	return nil
+
+	var out []string
+	for _, region := range opts.Regions {
+		if !slices.Contains(out, region) {
+			out = append(out, region)
+		}
+	}
+	return out, nil
 }
 
-func newAwsConfig(awsRegion string) (aws.Config, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// newAwsConfig loads aws config of the profile, loading is limited by the call timeout (see ImportOptions)
+func newAwsConfig(profile string, opts ImportOptions) (aws.Config, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultConfigTimeout
+	}
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithRetryer(newRetryer(opts.MaxRetries)),
+	}
+	if profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(profile))
+	}
+	// base endpoint applies to all clients created from this config (ec2, sts, iam, organizations)
+	if opts.EndpointUrl != "" {
+		loadOpts = append(loadOpts, config.WithBaseEndpoint(opts.EndpointUrl))
+	}
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+	cfg, err := callWithTimeout(timeout, func(ctx context.Context) (aws.Config, error) {
+		return config.LoadDefaultConfig(ctx, loadOpts...)
+	})
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("load aws config: %w", err)
 	}
 
-	if awsRegion == "" && cfg.Region == "" {
-		return aws.Config{}, errors.New("missing aws region")
+	// config region is used for sts, iam and ec2 describe regions calls, imported regions are resolved later
+	regions := opts.Regions
+	if len(regions) == 0 {
+		if cfg.Region == "" {
+			return aws.Config{}, errors.New("missing aws region")
+		}
+		return cfg, nil
 	}
-
-	if awsRegion != "" {
-		cfg.Region = awsRegion
+	if regions[0] != allRegions {
+		cfg.Region = regions[0]
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
 	}
 	return cfg, nil
 }
 
-func getCurrentAWSAccount(cfg aws.Config) (types.Account, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
+func getCurrentAWSAccount(cfg aws.Config, profile string, timeout time.Duration) (types.Account, error) {
 	stsSvc := sts.NewFromConfig(cfg)
-	callerIdentity, err := stsSvc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	callerIdentity, err := callWithTimeout(timeout, func(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+		return stsSvc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	})
 	if err != nil {
-		return types.Account{}, err
+		return types.Account{}, fmt.Errorf("get caller identity: %w", err)
 	}
 
 	iamSvc := iam.NewFromConfig(cfg)
-	accountAliases, err := iamSvc.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	accountAliases, err := callWithTimeout(timeout, func(ctx context.Context) (*iam.ListAccountAliasesOutput, error) {
+		return iamSvc.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	})
 	if err != nil {
-		return types.Account{}, err
+		return types.Account{}, fmt.Errorf("list account aliases: %w", err)
 	}
 
 	var accountAlias string
@@ -94,9 +262,41 @@ func getCurrentAWSAccount(cfg aws.Config) (types.Account, error) {
 		accountAlias = accountAliases.AccountAliases[0]
 	}
 
-	return types.Account{
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+
+	account := types.Account{
 		Id:      aws.ToString(callerIdentity.Account),
-		Profile: os.Getenv("AWS_PROFILE"),
+		Profile: profile,
 		Alias:   accountAlias,
-	}, nil
+	}
+	// credentials from env. variables or instance role have no profile
+	if profile != "" {
+		account.Profiles = []string{profile}
+	}
+	if v, err := arn.Parse(aws.ToString(callerIdentity.Arn)); err == nil {
+		account.Partition = v.Partition
+		if role := callerRole(v); role != "" {
+			account.Roles = []string{role}
+		}
+	}
+	return account, nil
+}
+
+// callerRole returns IAM role name of assumed role caller identity (e.g. arn:aws:sts::123456789012:assumed-role/
+// <role>/<session>), SSO roles (AWSReservedSSO_<permission set>_<id>) are returned as permission set name. Empty
+// string is returned if the caller is not assumed role (e.g. IAM user).
+func callerRole(callerArn arn.ARN) string {
+	resource, ok := strings.CutPrefix(callerArn.Resource, "assumed-role/")
+	if !ok {
+		return ""
+	}
+	role, _, _ := strings.Cut(resource, "/")
+	if permissionSet, ok := strings.CutPrefix(role, ssoRolePrefix); ok {
+		if i := strings.LastIndex(permissionSet, "_"); i > 0 {
+			return permissionSet[:i]
+		}
+	}
+	return role
 }
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewAwsConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	t.Setenv("AWS_REGION", "eu-west-2")
+
+	tests := []struct {
+		opts             ImportOptions
+		expectedRegion   string
+		expectedEndpoint *string
+	}{
+		{expectedRegion: "eu-west-2"},
+		{opts: ImportOptions{Regions: []string{"eu-west-1", "eu-central-1"}}, expectedRegion: "eu-west-1"},
+		{opts: ImportOptions{Regions: []string{"all"}}, expectedRegion: "eu-west-2"},
+		{
+			opts:             ImportOptions{EndpointUrl: "http://localhost:5000"},
+			expectedRegion:   "eu-west-2",
+			expectedEndpoint: aws.String("http://localhost:5000"),
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := newAwsConfig("", test.opts)
+		require.NoError(t, err)
+		assert.Equal(t, test.expectedRegion, cfg.Region)
+		assert.Equal(t, test.expectedEndpoint, cfg.BaseEndpoint)
+	}
+}
+
+func TestNewAwsConfig_missingRegion(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	_, err := newAwsConfig("", ImportOptions{})
+	assert.Error(t, err)
+
+	cfg, err := newAwsConfig("", ImportOptions{Regions: []string{"all"}})
+	require.NoError(t, err)
+	assert.Equal(t, defaultRegion, cfg.Region)
+}
+
+func TestCallerRole(t *testing.T) {
+	tests := []struct {
+		arn      string
+		expected string
+	}{
+		{arn: "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_ReadOnly_0123456789abcdef/jane", expected: "ReadOnly"},
+		{arn: "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_Power_User_0123456789abcdef/jane", expected: "Power_User"},
+		{arn: "arn:aws-us-gov:sts::123456789012:assumed-role/OrganizationAccountAccessRole/awf-import", expected: "OrganizationAccountAccessRole"},
+		{arn: "arn:aws:iam::123456789012:user/jane"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			v, err := arn.Parse(tt.arn)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, callerRole(v))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/internal/index"
+	"github.com/pete911/awf/internal/types"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// indexFile is prefix index of CIDRs and IPs of resources in the generation, see index.Trie
+const indexFile = "_index"
+
+// index values are <resource>/<id>, so only resources with matches are read
+const (
+	vpcIndexKey              = "vpc"
+	subnetIndexKey           = "subnet"
+	networkInterfaceIndexKey = "ni"
+)
+
+// LookupResult is resources with CIDR or IP that overlaps searched prefix
+type LookupResult struct {
+	Vpcs              types.Vpcs
+	Subnets           types.Subnets
+	NetworkInterfaces types.NetworkInterfaces
+}
+
+func (l *LookupResult) add(in LookupResult) {
+	l.Vpcs = append(l.Vpcs, in.Vpcs...)
+	l.Subnets = append(l.Subnets, in.Subnets...)
+	l.NetworkInterfaces = append(l.NetworkInterfaces, in.NetworkInterfaces...)
+}
+
+// indexResources adds CIDRs of VPCs and subnets, and IPs of network interfaces to the index
+func indexResources(idx *index.Trie, v any) {
+	switch in := v.(type) {
+	case types.Vpcs:
+		for _, vpc := range in {
+			idx.InsertString(vpc.CidrBlock, vpcIndexKey+"/"+vpc.VpcId)
+		}
+	case types.Subnets:
+		for _, subnet := range in {
+			idx.InsertString(subnet.CidrBlock, subnetIndexKey+"/"+subnet.SubnetId)
+		}
+	case types.NetworkInterfaces:
+		for _, ni := range in {
+			for _, ip := range uniqueIps(ni) {
+				idx.InsertString(ip, networkInterfaceIndexKey+"/"+ni.NetworkInterfaceId)
+			}
+		}
+	}
+}
+
+// uniqueIps returns private, public and IPv6 addresses of the network interface
+func uniqueIps(ni types.NetworkInterface) []string {
+	var out []string
+	for _, ip := range ni.Ips() {
+		if ip != "" && !slices.Contains(out, ip) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+// lookupIds returns ids of resources that overlap the prefix, grouped by resource (index key)
+func lookupIds(idx *index.Trie, prefix netip.Prefix) map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+	for _, v := range idx.Overlapping(prefix) {
+		resource, id, ok := strings.Cut(v, "/")
+		if !ok {
+			continue
+		}
+		if out[resource] == nil {
+			out[resource] = make(map[string]bool)
+		}
+		out[resource][id] = true
+	}
+	return out
+}
+
+// readIndex reads index of the generation, nil index is returned if the generation was imported before indexes were
+// introduced
+func readIndex(enc encoder, generationDir string) (*index.Trie, error) {
+	b, err := enc.readFile(filepath.Join(generationDir, indexFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	idx := index.New()
+	if err := idx.UnmarshalBinary(b); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(generationDir, indexFile), err)
+	}
+	return idx, nil
+}
+
+// idSet returns set of supplied ids, see filterById
+func idSet(ids []string) map[string]bool {
+	out := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out
+}
+
+func filterById[T any](in []T, ids map[string]bool, id func(T) string) []T {
+	var out []T
+	for _, v := range in {
+		if ids[id(v)] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// locksDir is directory of lock files in the store directory, it is hidden, so it is not listed as account
+	locksDir  = ".locks"
+	storeLock = "store"
+
+	// lockTimeout is how long lock is retried (e.g. while import commits), before LockedError is returned
+	lockTimeout    = 2 * time.Second
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// LockedError is returned when the store is locked by import (or other command that changes the store) and the lock
+// cannot be taken
+type LockedError struct {
+	Path string
+}
+
+func (e *LockedError) Error() string {
+	return "import in progress, retry or use --wait"
+}
+
+// lockPath returns path of the lock file in the store directory. Store locks are advisory (flock) locks, shared by all
+// awf processes:
+//
+//	.locks/store.lock              - store-wide lock, readers hold it shared, commit (swap and removal of snapshots)
+//	                                 holds it exclusive
+//	.locks/<account>.lock          - account metadata update (read, merge and write)
+//	.locks/<account>_<region>.lock - import of the account region, held from the start of the import to commit
+func lockPath(dir string, names ...string) string {
+	return filepath.Join(dir, locksDir, strings.Join(names, "_")+".lock")
+}
+
+// lock takes exclusive lock, it blocks until the lock is released by other writer. Returned function releases the
+// lock.
+func lock(path string) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	l := flock.New(path, flock.SetPermissions(0600))
+	if err := l.Lock(); err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return l.Close, nil
+}
+
+// rlock takes shared lock, see tryLock
+func rlock(path string, wait bool) (func() error, error) {
+	return acquire(path, true, wait)
+}
+
+// tryLock takes exclusive lock. If wait is not set, the lock is retried for lockTimeout and LockedError is returned if
+// the lock is still held (e.g. by running import).
+func tryLock(path string, wait bool) (func() error, error) {
+	return acquire(path, false, wait)
+}
+
+func acquire(path string, shared, wait bool) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	l := flock.New(path, flock.SetPermissions(0600))
+	if wait {
+		lockFn := l.Lock
+		if shared {
+			lockFn = l.RLock
+		}
+		if err := lockFn(); err != nil {
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		return l.Close, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+	tryFn := l.TryLockContext
+	if shared {
+		tryFn = l.TryRLockContext
+	}
+	ok, err := tryFn(ctx, lockRetryDelay)
+	if ok {
+		return l.Close, nil
+	}
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return nil, &LockedError{Path: path}
+}
+
+// isLocked returns true if the lock is held, lock that does not exist is not held
+func isLocked(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	l := flock.New(path)
+	defer l.Close()
+	ok, err := l.TryLock()
+	return err != nil || !ok
+}
+
+// RLock takes shared lock of stores selected by options (see Load). Commands that read the store hold it, so import
+// cannot replace or remove snapshots that are being read. If import is committing, LockedError is returned, unless
+// Wait is set. Stores that do not exist yet are not locked. Returned function releases the lock.
+func RLock(opts Options) (func() error, error) {
+	dir := opts.Dir
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			return nil, err
+		}
+	}
+	dirs, err := ResolveWorkspaces(dir, opts.Workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	var unlocks []func() error
+	unlock := func() error {
+		var errs []error
+		for _, fn := range unlocks {
+			errs = append(errs, fn())
+		}
+		return errors.Join(errs...)
+	}
+	for _, workspaceDir := range dirs {
+		if _, err := os.Stat(workspaceDir); err != nil {
+			continue
+		}
+		fn, err := rlock(lockPath(workspaceDir, storeLock), opts.Wait)
+		if err != nil {
+			return nil, errors.Join(err, unlock())
+		}
+		unlocks = append(unlocks, fn)
+	}
+	return unlock, nil
+}
+
+// lockRemoval takes locks of the account and the account regions and then exclusive store lock, in the same order as
+// import, so removal does not change data of running import or data that are being read.
+// LockedError is returned if the account or region is being imported, unless wait is set.
+func lockRemoval(dir string, wait bool, accountId string, regions ...string) (func() error, error) {
+	var unlocks []func() error
+	unlock := func() error {
+		var errs []error
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			errs = append(errs, unlocks[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	paths := []string{lockPath(dir, accountId)}
+	for _, region := range regions {
+		paths = append(paths, lockPath(dir, accountId, region))
+	}
+	for _, path := range paths {
+		fn, err := tryLock(path, wait)
+		if err != nil {
+			return nil, errors.Join(err, unlock())
+		}
+		unlocks = append(unlocks, fn)
+	}
+
+	fn, err := lock(lockPath(dir, storeLock))
+	if err != nil {
+		return nil, errors.Join(err, unlock())
+	}
+	unlocks = append(unlocks, fn)
+	return unlock, nil
+}
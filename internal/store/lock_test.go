@@ -0,0 +1,52 @@
+package store
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRLock(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+
+	// commit holds exclusive store lock
+	unlock, err := lock(lockPath(f.dir, storeLock))
+	require.NoError(t, err)
+	_, err = RLock(Options{Dir: f.dir})
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	require.NoError(t, unlock())
+
+	runlock, err := RLock(Options{Dir: f.dir})
+	require.NoError(t, err)
+	require.NoError(t, runlock())
+}
+
+func TestGeneration_lock(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+
+	// concurrent import of the same region waits for the running import
+	first := writeTestGeneration(t, f, account.Id, "eu-west-2", "vpc-1")
+	done := make(chan *generation)
+	go func() {
+		gen, err := f.newGeneration(account.Id, "eu-west-2", Retention{})
+		assert.NoError(t, err)
+		done <- gen
+	}()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("region import did not wait for running import")
+	default:
+	}
+	require.NoError(t, first.Commit())
+	require.NoError(t, (<-done).Discard())
+
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+}
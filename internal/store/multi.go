@@ -0,0 +1,230 @@
+package store
+
+import (
+	"errors"
+	"github.com/pete911/awf/internal/types"
+	"net/netip"
+	"time"
+)
+
+var _ Store = Multi{}
+
+// Multi reads several stores (workspaces) together, results of every store are concatenated. Account that was
+// imported to more than one store is listed once, account and region metadata are read from the first store that has
+// the account. Multi store is read only, import writes to a single workspace.
+type Multi struct {
+	stores []Store
+}
+
+func NewMulti(stores ...Store) Multi {
+	return Multi{stores: stores}
+}
+
+// ListAccounts returns accounts of all stores, NotFoundError is returned only if none of the stores exists
+func (m Multi) ListAccounts() (types.Accounts, error) {
+	var accounts types.Accounts
+	var found bool
+	for _, s := range m.stores {
+		storeAccounts, err := s.ListAccounts()
+		if err != nil {
+			var notFound *NotFoundError
+			if errors.As(err, &notFound) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		for _, account := range storeAccounts {
+			if accounts.GetById(account.Id).Id == "" {
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	if !found {
+		return nil, NewNotFoundError("read: workspaces are empty, run 'awf import' first")
+	}
+	return accounts, nil
+}
+
+// storeOf returns the first store that has the account
+func (m Multi) storeOf(account types.Account) (Store, bool) {
+	for _, s := range m.stores {
+		accounts, err := s.ListAccounts()
+		if err == nil && accounts.GetById(account.Id).Id != "" {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func (m Multi) ListRegions(account types.Account) ([]string, error) {
+	s, ok := m.storeOf(account)
+	if !ok {
+		return nil, nil
+	}
+	return s.ListRegions(account)
+}
+
+func (m Multi) ListResources(account types.Account, region string) ([]Resource, error) {
+	s, ok := m.storeOf(account)
+	if !ok {
+		return nil, nil
+	}
+	return s.ListResources(account, region)
+}
+
+func (m Multi) ImportedAt(account types.Account, region string) (time.Time, error) {
+	s, ok := m.storeOf(account)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return s.ImportedAt(account, region)
+}
+
+func (m Multi) ListSnapshots(account types.Account, region string) ([]time.Time, error) {
+	s, ok := m.storeOf(account)
+	if !ok {
+		return nil, nil
+	}
+	return s.ListSnapshots(account, region)
+}
+
+func (m Multi) Snapshot(account types.Account, region string) (time.Time, bool) {
+	s, ok := m.storeOf(account)
+	if !ok {
+		return time.Time{}, false
+	}
+	return s.Snapshot(account, region)
+}
+
+func (m Multi) At(t time.Time) Store {
+	stores := make([]Store, 0, len(m.stores))
+	for _, s := range m.stores {
+		stores = append(stores, s.At(t))
+	}
+	return NewMulti(stores...)
+}
+
+func (m Multi) DescribeVpcs() (types.Vpcs, error) {
+	return readAll(m, Store.DescribeVpcs)
+}
+
+func (m Multi) DescribeSubnets() (types.Subnets, error) {
+	return readAll(m, Store.DescribeSubnets)
+}
+
+func (m Multi) DescribeNetworkInterfaces() (types.NetworkInterfaces, error) {
+	return readAll(m, Store.DescribeNetworkInterfaces)
+}
+
+func (m Multi) GetNetworkInterfacesByIp(ip string) (types.NetworkInterfaces, error) {
+	return readAll(m, func(s Store) (types.NetworkInterfaces, error) { return s.GetNetworkInterfacesByIp(ip) })
+}
+
+func (m Multi) GetNetworkInterfacesById(ids ...string) (types.NetworkInterfaces, error) {
+	return readAll(m, func(s Store) (types.NetworkInterfaces, error) { return s.GetNetworkInterfacesById(ids...) })
+}
+
+func (m Multi) GetVpcsById(ids ...string) (types.Vpcs, error) {
+	return readAll(m, func(s Store) (types.Vpcs, error) { return s.GetVpcsById(ids...) })
+}
+
+func (m Multi) GetSubnetsById(ids ...string) (types.Subnets, error) {
+	return readAll(m, func(s Store) (types.Subnets, error) { return s.GetSubnetsById(ids...) })
+}
+
+func (m Multi) Related(vpcs types.Vpcs, subnets types.Subnets) (LookupResult, error) {
+	var result LookupResult
+	err := m.forEach(func(s Store) error {
+		storeResult, err := s.Related(vpcs, subnets)
+		if err != nil {
+			return err
+		}
+		result.add(storeResult)
+		return nil
+	})
+	return result, err
+}
+
+func (m Multi) Lookup(prefix netip.Prefix) (LookupResult, error) {
+	var result LookupResult
+	err := m.forEach(func(s Store) error {
+		storeResult, err := s.Lookup(prefix)
+		if err != nil {
+			return err
+		}
+		result.add(storeResult)
+		return nil
+	})
+	return result, err
+}
+
+func (m Multi) WalkNetworkInterfaces(fn func(account types.Account, region string, snapshot time.Time, nis types.NetworkInterfaces)) error {
+	return m.forEach(func(s Store) error {
+		return s.WalkNetworkInterfaces(fn)
+	})
+}
+
+func (m Multi) Warnings() []string {
+	var out []string
+	for _, s := range m.stores {
+		out = append(out, s.Warnings()...)
+	}
+	return out
+}
+
+func (m Multi) WriteAccount(types.Account) error {
+	return errors.New("import to multiple workspaces is not supported, select single workspace")
+}
+
+func (m Multi) NewRegionWriter(types.Account, string, Retention) (RegionWriter, error) {
+	return nil, errors.New("import to multiple workspaces is not supported, select single workspace")
+}
+
+func (m Multi) RemoveAccount(types.Account) error {
+	return errors.New("remove from multiple workspaces is not supported, select single workspace")
+}
+
+func (m Multi) RemoveRegion(types.Account, string) error {
+	return errors.New("remove from multiple workspaces is not supported, select single workspace")
+}
+
+func (m Multi) RemoveSnapshots(types.Account, string, []time.Time) error {
+	return errors.New("remove from multiple workspaces is not supported, select single workspace")
+}
+
+// forEach calls fn for every store, stores that do not exist are skipped. NotFoundError is returned only if none of
+// the stores exists.
+func (m Multi) forEach(fn func(s Store) error) error {
+	var found bool
+	for _, s := range m.stores {
+		if _, err := s.ListAccounts(); err != nil {
+			var notFound *NotFoundError
+			if errors.As(err, &notFound) {
+				continue
+			}
+			return err
+		}
+		found = true
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return NewNotFoundError("read: workspaces are empty, run 'awf import' first")
+	}
+	return nil
+}
+
+func readAll[S ~[]E, E any](m Multi, read func(s Store) (S, error)) (S, error) {
+	var out S
+	err := m.forEach(func(s Store) error {
+		v, err := read(s)
+		if err != nil {
+			return err
+		}
+		out = append(out, v...)
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestMulti(t *testing.T) {
+	prod, dev := types.Account{Id: "123456789012"}, types.Account{Id: "210987654321"}
+	prodFile, devFile := newTestFile(t, prod), newTestFile(t, dev)
+	require.NoError(t, writeTestGeneration(t, prodFile, prod.Id, "eu-west-2", "vpc-1").Commit())
+	require.NoError(t, writeTestGeneration(t, devFile, dev.Id, "eu-west-1", "vpc-2").Commit())
+	empty := File{dir: filepath.Join(t.TempDir(), "empty"), strict: true, warnings: &warnings{}}
+
+	m := NewMulti(prodFile, devFile, empty)
+	accounts, err := m.ListAccounts()
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	regions, err := m.ListRegions(dev)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1"}, regions)
+
+	vpcs, err := m.DescribeVpcs()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vpc-1", "vpc-2"}, vpcIds(vpcs))
+
+	_, err = NewMulti(empty).DescribeVpcs()
+	var notFound *NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Error(t, m.WriteAccount(prod))
+}
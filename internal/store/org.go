@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pete911/awf/internal/types"
+	"time"
+)
+
+const roleSessionName = "awf-import"
+
+const orgAccountsStep = "organizations.list-accounts"
+
+type orgAccount struct {
+	Id        string
+	Name      string
+	Partition string
+}
+
+// ImportOrganization lists active accounts in the organization and imports each account by assuming supplied role.
+// Profile has to belong to the management (or delegated administrator) account, that account is imported with the
+// profile credentials (role does not have to exist in it). Number of concurrent account imports is limited by workers.
+func ImportOrganization(profile, roleName string, opts ImportOptions) ImportResult {
+	cfg, err := newAwsConfig(profile, opts)
+	if err != nil {
+		return ImportResult{{Profile: profile, Importer: configStep, Err: err}}
+	}
+
+	start := time.Now()
+	caller, err := getCurrentAWSAccount(cfg, profile, opts.Timeout)
+	if err != nil {
+		return ImportResult{{Profile: profile, Region: cfg.Region, Importer: accountStep, Duration: time.Since(start), Err: err}}
+	}
+
+	start = time.Now()
+	accounts, err := listOrganizationAccounts(cfg, opts.Timeout)
+	if err != nil {
+		return ImportResult{{Profile: profile, Region: cfg.Region, Importer: orgAccountsStep, Duration: time.Since(start), Err: err}}
+	}
+
+	results := &importResults{}
+	runWorkers(len(accounts), opts.Workers, func(i int) {
+		importOrgAccount(cfg, caller, accounts[i], roleName, opts, results)
+	})
+	return results.result()
+}
+
+func importOrgAccount(cfg aws.Config, caller types.Account, orgAccount orgAccount, roleName string, opts ImportOptions, results *importResults) {
+	start := time.Now()
+	account, accountCfg, err := orgAccountConfig(cfg, caller, orgAccount, roleName, opts.Timeout)
+	if err != nil {
+		results.add(ImportEntry{
+			AccountId: orgAccount.Id,
+			Region:    cfg.Region,
+			Importer:  accountStep,
+			Duration:  time.Since(start),
+			Err:       err,
+		})
+		return
+	}
+	importAccount(account, accountCfg, opts, results)
+}
+
+// orgAccountConfig returns organization account and config to import it with. Caller account is accessed with caller
+// credentials, other accounts by assuming the role.
+func orgAccountConfig(cfg aws.Config, caller types.Account, orgAccount orgAccount, roleName string, timeout time.Duration) (types.Account, aws.Config, error) {
+	if orgAccount.Id == caller.Id {
+		caller.Name = orgAccount.Name
+		return caller, cfg, nil
+	}
+
+	accountCfg := assumeRoleConfig(cfg, orgAccount.roleArn(roleName))
+	account, err := getCurrentAWSAccount(accountCfg, "", timeout)
+	if err != nil {
+		return types.Account{}, aws.Config{}, fmt.Errorf("assume role %s: %w", orgAccount.roleArn(roleName), err)
+	}
+	// profile (or AWS_PROFILE) belongs to the management account, it cannot be used to access this account
+	account.Profile = ""
+	account.Profiles = nil
+	account.Name = orgAccount.Name
+	return account, accountCfg, nil
+}
+
+// assumeRoleConfig returns copy of supplied config with credentials of assumed role
+func assumeRoleConfig(cfg aws.Config, roleArn string) aws.Config {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+	})
+
+	out := cfg.Copy()
+	out.Credentials = aws.NewCredentialsCache(provider)
+	return out
+}
+
+// listOrganizationAccounts returns active organization accounts, suspended and closed accounts are skipped
+func listOrganizationAccounts(cfg aws.Config, timeout time.Duration) ([]orgAccount, error) {
+	svc := organizations.NewFromConfig(cfg)
+	var accounts []orgAccount
+	in := &organizations.ListAccountsInput{}
+	for {
+		out, err := callWithTimeout(timeout, func(ctx context.Context) (*organizations.ListAccountsOutput, error) {
+			return svc.ListAccounts(ctx, in)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list organization accounts: %w", err)
+		}
+		for _, v := range out.Accounts {
+			if isActiveOrgAccount(v) {
+				accounts = append(accounts, toOrgAccount(v))
+			}
+		}
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+	return accounts, nil
+}
+
+func isActiveOrgAccount(in orgtypes.Account) bool {
+	// status is deprecated in favour of state, but not every endpoint returns state yet
+	if in.State != "" {
+		return in.State == orgtypes.AccountStateActive
+	}
+	return in.Status == orgtypes.AccountStatusActive
+}
+
+func toOrgAccount(in orgtypes.Account) orgAccount {
+	partition := "aws"
+	if v, err := arn.Parse(aws.ToString(in.Arn)); err == nil {
+		partition = v.Partition
+	}
+	return orgAccount{
+		Id:        aws.ToString(in.Id),
+		Name:      aws.ToString(in.Name),
+		Partition: partition,
+	}
+}
+
+func (a orgAccount) roleArn(roleName string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", a.Partition, a.Id, roleName)
+}
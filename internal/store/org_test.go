@@ -0,0 +1,130 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAws is a local fake of organizations, sts and iam endpoints. Organizations uses json protocol (operation is set
+// in X-Amz-Target header), sts and iam use query protocol (operation is set in Action form value).
+type fakeAws struct {
+	assumedRoles []string
+	callerKeys   []string
+}
+
+func (f *fakeAws) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		f.serveOrganizations(w, r, target)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	switch r.Form.Get("Action") {
+	case "AssumeRole":
+		f.assumedRoles = append(f.assumedRoles, r.Form.Get("RoleArn"))
+		accountId := strings.Split(r.Form.Get("RoleArn"), ":")[4]
+		fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>ASIA%s</AccessKeyId>
+<SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration>
+</Credentials></AssumeRoleResult></AssumeRoleResponse>`, accountId)
+	case "GetCallerIdentity":
+		accessKey := strings.Split(strings.Split(r.Header.Get("Authorization"), "Credential=")[1], "/")[0]
+		f.callerKeys = append(f.callerKeys, accessKey)
+		fmt.Fprintf(w, `<GetCallerIdentityResponse><GetCallerIdentityResult><Account>%s</Account>
+</GetCallerIdentityResult></GetCallerIdentityResponse>`, strings.TrimPrefix(accessKey, "ASIA"))
+	case "ListAccountAliases":
+		fmt.Fprint(w, `<ListAccountAliasesResponse><ListAccountAliasesResult><IsTruncated>false</IsTruncated>
+<AccountAliases><member>test-alias</member></AccountAliases></ListAccountAliasesResult></ListAccountAliasesResponse>`)
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeAws) serveOrganizations(w http.ResponseWriter, r *http.Request, target string) {
+	if target != "AWSOrganizationsV20161128.ListAccounts" {
+		http.Error(w, "unknown target", http.StatusBadRequest)
+		return
+	}
+
+	var in struct{ NextToken string }
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if in.NextToken == "" {
+		fmt.Fprint(w, `{"NextToken": "page-2", "Accounts": [
+{"Id": "111111111111", "Name": "management", "Arn": "arn:aws:organizations::111111111111:account/o-test/111111111111", "State": "ACTIVE"},
+{"Id": "333333333333", "Name": "suspended", "Arn": "arn:aws:organizations::111111111111:account/o-test/333333333333", "State": "SUSPENDED"}]}`)
+		return
+	}
+	fmt.Fprint(w, `{"Accounts": [
+{"Id": "222222222222", "Name": "gov", "Arn": "arn:aws-us-gov:organizations::111111111111:account/o-test/222222222222", "Status": "ACTIVE"}]}`)
+}
+
+func newFakeAwsConfig(t *testing.T, fake *fakeAws) aws.Config {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKIAMANAGEMENT", "secret", ""),
+	}
+}
+
+func TestListOrganizationAccounts(t *testing.T) {
+	cfg := newFakeAwsConfig(t, &fakeAws{})
+
+	accounts, err := listOrganizationAccounts(cfg, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []orgAccount{
+		{Id: "111111111111", Name: "management", Partition: "aws"},
+		{Id: "222222222222", Name: "gov", Partition: "aws-us-gov"},
+	}, accounts)
+	assert.Equal(t, "arn:aws-us-gov:iam::222222222222:role/test", accounts[1].roleArn("test"))
+}
+
+func TestAssumeRoleConfig(t *testing.T) {
+	fake := &fakeAws{}
+	cfg := newFakeAwsConfig(t, fake)
+
+	account, err := getCurrentAWSAccount(assumeRoleConfig(cfg, "arn:aws:iam::222222222222:role/test"), "test", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "222222222222", account.Id)
+	assert.Equal(t, "test-alias", account.Alias)
+	assert.Equal(t, []string{"arn:aws:iam::222222222222:role/test"}, fake.assumedRoles)
+	assert.Equal(t, []string{"ASIA222222222222"}, fake.callerKeys)
+}
+
+func TestOrgAccountConfig(t *testing.T) {
+	fake := &fakeAws{}
+	cfg := newFakeAwsConfig(t, fake)
+	caller := types.Account{Id: "111111111111", Profile: "management", Profiles: []string{"management"}}
+
+	// caller account is imported with caller credentials, role is not assumed
+	account, accountCfg, err := orgAccountConfig(cfg, caller, orgAccount{Id: "111111111111", Name: "management", Partition: "aws"}, "test", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, types.Account{Id: "111111111111", Name: "management", Profile: "management", Profiles: []string{"management"}}, account)
+	assert.Equal(t, cfg.Credentials, accountCfg.Credentials)
+	assert.Empty(t, fake.assumedRoles)
+
+	account, _, err = orgAccountConfig(cfg, caller, orgAccount{Id: "222222222222", Name: "dev", Partition: "aws"}, "test", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "222222222222", account.Id)
+	assert.Equal(t, "dev", account.Name)
+	assert.Empty(t, account.Profile)
+	assert.Equal(t, []string{"arn:aws:iam::222222222222:role/test"}, fake.assumedRoles)
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ListProfiles returns sorted profile names from shared aws config and credentials files. Include and exclude
+// are glob patterns (e.g. 'prod-*'), empty include matches all profiles.
+func ListProfiles(include, exclude []string) ([]string, error) {
+	configProfiles, err := readProfiles(sharedConfigFilename(), true)
+	if err != nil {
+		return nil, err
+	}
+	credentialsProfiles, err := readProfiles(sharedCredentialsFilename(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []string
+	for _, profile := range append(configProfiles, credentialsProfiles...) {
+		if slices.Contains(profiles, profile) {
+			continue
+		}
+		ok, err := matchProfile(profile, include, exclude)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			profiles = append(profiles, profile)
+		}
+	}
+	slices.Sort(profiles)
+	return profiles, nil
+}
+
+func matchProfile(profile string, include, exclude []string) (bool, error) {
+	for _, pattern := range exclude {
+		ok, err := path.Match(pattern, profile)
+		if err != nil {
+			return false, fmt.Errorf("exclude pattern %s: %w", pattern, err)
+		}
+		if ok {
+			return false, nil
+		}
+	}
+
+	if len(include) == 0 {
+		return true, nil
+	}
+	for _, pattern := range include {
+		ok, err := path.Match(pattern, profile)
+		if err != nil {
+			return false, fmt.Errorf("include pattern %s: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readProfiles reads profile names from shared config or credentials file. Config file has profiles in
+// [profile <name>] sections (except default profile), credentials file in [<name>] sections. Missing file is
+// not an error, profiles can be defined only in one of the files.
+func readProfiles(filename string, isConfig bool) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var profiles []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if profile, ok := parseProfileSection(scanner.Text(), isConfig); ok {
+			profiles = append(profiles, profile)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", filename, err)
+	}
+	return profiles, nil
+}
+
+func parseProfileSection(line string, isConfig bool) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+
+	section := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+	if !isConfig || section == "default" {
+		return section, section != ""
+	}
+	// config file contains other sections as well e.g. [sso-session <name>] or [services <name>]
+	if name, ok := strings.CutPrefix(section, "profile "); ok {
+		name = strings.TrimSpace(name)
+		return name, name != ""
+	}
+	return "", false
+}
+
+func sharedConfigFilename() string {
+	if v := os.Getenv("AWS_CONFIG_FILE"); v != "" {
+		return v
+	}
+	return config.DefaultSharedConfigFilename()
+}
+
+func sharedCredentialsFilename() string {
+	if v := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); v != "" {
+		return v
+	}
+	return config.DefaultSharedCredentialsFilename()
+}
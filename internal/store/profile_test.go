@@ -0,0 +1,70 @@
+package store
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testConfigFile = `[default]
+region = eu-west-2
+
+[profile prod-admin]
+sso_session = test
+
+[profile  dev-admin ]
+region = eu-west-1
+
+[sso-session test]
+sso_region = eu-west-1
+
+[services local]
+ec2 =
+  endpoint_url = http://localhost:5000
+`
+	testCredentialsFile = `[default]
+aws_access_key_id = test
+
+[prod-readonly]
+aws_access_key_id = test
+`
+)
+
+func TestListProfiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte(testConfigFile), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "credentials"), []byte(testCredentialsFile), 0600))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	tests := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{expected: []string{"default", "dev-admin", "prod-admin", "prod-readonly"}},
+		{include: []string{"prod-*"}, expected: []string{"prod-admin", "prod-readonly"}},
+		{include: []string{"prod-*"}, exclude: []string{"*-admin"}, expected: []string{"prod-readonly"}},
+		{exclude: []string{"default", "prod-*"}, expected: []string{"dev-admin"}},
+		{include: []string{"test"}, expected: nil},
+	}
+
+	for _, test := range tests {
+		profiles, err := ListProfiles(test.include, test.exclude)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, profiles)
+	}
+}
+
+func TestListProfiles_missingFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	profiles, err := ListProfiles(nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, profiles)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"slices"
+	"strings"
+)
+
+// relatedIds are IDs of VPCs and subnets of one account region, that are used to read related resources, see Related
+type relatedIds struct {
+	account types.Account
+	region  string
+	// vpcIds are VPCs whose subnets and network interfaces are read
+	vpcIds map[string]bool
+	// subnetIds are subnets whose network interfaces are read
+	subnetIds map[string]bool
+	// subnetVpcIds are VPCs of the subnets
+	subnetVpcIds map[string]bool
+}
+
+// groupRelated groups IDs of VPCs and subnets by account region, groups are sorted by account and region
+func groupRelated(vpcs types.Vpcs, subnets types.Subnets) []*relatedIds {
+	groups := make(map[string]*relatedIds)
+	group := func(account types.Account, region string) *relatedIds {
+		key := account.Id + "/" + region
+		if _, ok := groups[key]; !ok {
+			groups[key] = &relatedIds{
+				account:      account,
+				region:       region,
+				vpcIds:       make(map[string]bool),
+				subnetIds:    make(map[string]bool),
+				subnetVpcIds: make(map[string]bool),
+			}
+		}
+		return groups[key]
+	}
+
+	for _, v := range vpcs {
+		group(v.Account, v.Region).vpcIds[v.VpcId] = true
+	}
+	for _, v := range subnets {
+		g := group(v.Account, v.Region)
+		g.subnetIds[v.SubnetId] = true
+		g.subnetVpcIds[v.VpcId] = true
+	}
+
+	out := make([]*relatedIds, 0, len(groups))
+	for _, v := range groups {
+		out = append(out, v)
+	}
+	slices.SortFunc(out, func(a, b *relatedIds) int {
+		if c := strings.Compare(a.account.Id, b.account.Id); c != 0 {
+			return c
+		}
+		return strings.Compare(a.region, b.region)
+	})
+	return out
+}
+
+// related filters resources of the account region: VPCs of the subnets, subnets in the VPCs and network interfaces in
+// the VPCs or subnets
+func (r *relatedIds) related(vpcs types.Vpcs, subnets types.Subnets, nis types.NetworkInterfaces) LookupResult {
+	var out LookupResult
+	out.Vpcs = filterById(vpcs, r.subnetVpcIds, func(v types.Vpc) string { return v.VpcId })
+	out.Subnets = filterById(subnets, r.vpcIds, func(v types.Subnet) string { return v.VpcId })
+	for _, v := range nis {
+		if r.vpcIds[v.VpcId] || r.subnetIds[v.SubnetId] {
+			out.NetworkInterfaces = append(out.NetworkInterfaces, v)
+		}
+	}
+	return out
+}
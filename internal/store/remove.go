@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"time"
+)
+
+// RemoveFilter selects data that are removed. Accounts (IDs) and Regions limit removal, empty field selects everything.
+// If Regions are empty, whole accounts (with account metadata) are removed, otherwise only the regions. OlderThan
+// selects accounts (or regions) whose latest import is older than supplied duration ago, zero OlderThan is not set.
+type RemoveFilter struct {
+	Accounts  []string
+	Regions   []string
+	OlderThan time.Duration
+}
+
+// PruneOptions selects snapshots that are removed by PlanPrune. Accounts (IDs) and Regions limit pruning, empty field
+// selects everything. Snapshots out of Retention are removed, current snapshot is always kept.
+type PruneOptions struct {
+	Accounts  []string
+	Regions   []string
+	Retention Retention
+}
+
+// Removal is planned removal of the account (Region is empty), the account region, or snapshots of the account region
+// (Snapshots are set). ImportedAt is the latest import time, zero if the account has no imports.
+type Removal struct {
+	Account    types.Account
+	Region     string
+	ImportedAt time.Time
+	Snapshots  []time.Time
+}
+
+// PlanRemove returns accounts or account regions selected by filter, nothing is removed (see Remove), so the plan
+// can be printed as dry run
+func PlanRemove(s Store, filter RemoveFilter) ([]Removal, error) {
+	if filter.OlderThan < 0 {
+		return nil, fmt.Errorf("invalid older than duration %s, duration cannot be negative", filter.OlderThan)
+	}
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Removal
+	for _, account := range accounts {
+		if !matchFilter(filter.Accounts, account.Id) {
+			continue
+		}
+		regions, err := s.ListRegions(account)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", account.Id, err)
+		}
+
+		if len(filter.Regions) == 0 {
+			var latest time.Time
+			for _, region := range regions {
+				importedAt, err := regionImportTime(s, account, region)
+				if err != nil {
+					return nil, fmt.Errorf("account %s region %s: %w", account.Id, region, err)
+				}
+				if importedAt.After(latest) {
+					latest = importedAt
+				}
+			}
+			if isOlderThan(latest, filter.OlderThan) {
+				out = append(out, Removal{Account: account, ImportedAt: latest})
+			}
+			continue
+		}
+
+		for _, region := range regions {
+			if !matchFilter(filter.Regions, region) {
+				continue
+			}
+			importedAt, err := regionImportTime(s, account, region)
+			if err != nil {
+				return nil, fmt.Errorf("account %s region %s: %w", account.Id, region, err)
+			}
+			if isOlderThan(importedAt, filter.OlderThan) {
+				out = append(out, Removal{Account: account, Region: region, ImportedAt: importedAt})
+			}
+		}
+	}
+	return out, nil
+}
+
+// PlanPrune returns snapshots of account regions that are out of retention, nothing is removed (see Remove)
+func PlanPrune(s Store, opts PruneOptions) ([]Removal, error) {
+	if opts.Retention.Count <= 0 && opts.Retention.MaxAge <= 0 {
+		return nil, errors.New("set number of kept snapshots or their max age")
+	}
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Removal
+	for _, account := range accounts {
+		if !matchFilter(opts.Accounts, account.Id) {
+			continue
+		}
+		regions, err := s.ListRegions(account)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", account.Id, err)
+		}
+		for _, region := range regions {
+			if !matchFilter(opts.Regions, region) {
+				continue
+			}
+			snapshots, err := s.ListSnapshots(account, region)
+			if err != nil {
+				return nil, fmt.Errorf("account %s region %s: %w", account.Id, region, err)
+			}
+			if len(snapshots) == 0 {
+				continue
+			}
+			current := snapshots[len(snapshots)-1]
+			if prune := outOfRetention(snapshots, current, opts.Retention); len(prune) > 0 {
+				out = append(out, Removal{Account: account, Region: region, ImportedAt: current, Snapshots: prune})
+			}
+		}
+	}
+	return out, nil
+}
+
+// Remove removes planned accounts, regions and snapshots. Removals that fail (e.g. account that is being imported) are
+// skipped and their errors returned, number of completed removals is returned.
+func Remove(s Store, removals []Removal) (int, error) {
+	var removed int
+	var errs []error
+	for _, v := range removals {
+		var err error
+		switch {
+		case v.Region == "":
+			err = s.RemoveAccount(v.Account)
+		case len(v.Snapshots) == 0:
+			err = s.RemoveRegion(v.Account, v.Region)
+		default:
+			err = s.RemoveSnapshots(v.Account, v.Region, v.Snapshots)
+		}
+		if err != nil {
+			if v.Region != "" {
+				err = fmt.Errorf("region %s: %w", v.Region, err)
+			}
+			errs = append(errs, fmt.Errorf("account %s: %w", v.Account.Id, err))
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// regionImportTime returns import time of the current snapshot of the region, regions imported before snapshots were
+// introduced return import time of their resources
+func regionImportTime(s Store, account types.Account, region string) (time.Time, error) {
+	if t, ok := s.Snapshot(account, region); ok {
+		return t, nil
+	}
+	return s.ImportedAt(account, region)
+}
+
+// isOlderThan returns true if t is older than d ago, or if d is not set (zero)
+func isOlderThan(t time.Time, d time.Duration) bool {
+	if d == 0 {
+		return true
+	}
+	return time.Since(t) > d
+}
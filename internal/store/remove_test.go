@@ -0,0 +1,108 @@
+package store
+
+import (
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPlanRemove(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	now := time.Now().UTC()
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", now.Add(-48*time.Hour), "vpc-1")
+	writeTestSnapshot(t, f, account.Id, "eu-west-1", now.Add(-time.Hour), "vpc-2")
+
+	tests := []struct {
+		name     string
+		filter   RemoveFilter
+		expected []string
+	}{
+		{name: "all", expected: []string{""}},
+		{name: "other account", filter: RemoveFilter{Accounts: []string{"210987654321"}}},
+		{name: "account imported recently", filter: RemoveFilter{OlderThan: 24 * time.Hour}},
+		{name: "regions", filter: RemoveFilter{Regions: []string{"eu-west-1", "eu-west-2"}}, expected: []string{"eu-west-1", "eu-west-2"}},
+		{name: "old regions", filter: RemoveFilter{Regions: []string{"eu-west-1", "eu-west-2"}, OlderThan: 24 * time.Hour}, expected: []string{"eu-west-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removals, err := PlanRemove(f, tt.filter)
+			require.NoError(t, err)
+			var regions []string
+			for _, v := range removals {
+				regions = append(regions, v.Region)
+			}
+			assert.Equal(t, tt.expected, regions)
+		})
+	}
+
+	_, err := PlanRemove(f, RemoveFilter{OlderThan: -24 * time.Hour})
+	assert.Error(t, err)
+}
+
+func TestRemove(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	now := time.Now().UTC()
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", now.Add(-48*time.Hour), "vpc-1")
+	writeTestSnapshot(t, f, account.Id, "eu-west-1", now.Add(-time.Hour), "vpc-2")
+
+	removals, err := PlanRemove(f, RemoveFilter{Regions: []string{"eu-west-2"}})
+	require.NoError(t, err)
+	removed, err := Remove(f, removals)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	vpcs, err := f.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-2"}, vpcIds(vpcs))
+
+	// region that is being imported is not removed
+	gen, err := f.newGeneration(account.Id, "eu-west-1", Retention{})
+	require.NoError(t, err)
+	removals, err = PlanRemove(f, RemoveFilter{})
+	require.NoError(t, err)
+	removed, err = Remove(f, removals)
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.Equal(t, 0, removed)
+	require.NoError(t, gen.Discard())
+
+	removed, err = Remove(f, removals)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	accounts, err := f.ListAccounts()
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+}
+
+func TestPlanPrune(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	f := newTestFile(t, account)
+	now := time.Now().UTC()
+	for _, days := range []int{100, 20, 10, 5} {
+		writeTestSnapshot(t, f, account.Id, "eu-west-2", now.Add(-time.Duration(days)*24*time.Hour))
+	}
+
+	_, err := PlanPrune(f, PruneOptions{})
+	assert.Error(t, err)
+
+	// current snapshot is kept, even if it is out of retention
+	removals, err := PlanPrune(f, PruneOptions{Retention: Retention{MaxAge: 24 * time.Hour}})
+	require.NoError(t, err)
+	require.Len(t, removals, 1)
+	assert.Len(t, removals[0].Snapshots, 3)
+
+	removed, err := Remove(f, removals)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	snapshots, err := f.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.WithinDuration(t, now.Add(-5*24*time.Hour), snapshots[0], time.Second)
+
+	removals, err = PlanPrune(f, PruneOptions{Retention: Retention{Count: 1}})
+	require.NoError(t, err)
+	assert.Empty(t, removals)
+}
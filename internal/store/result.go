@@ -0,0 +1,121 @@
+package store
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+type ImportStatus string
+
+const (
+	ImportOk      ImportStatus = "ok"
+	ImportPartial ImportStatus = "partial"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportResult contains entry for every imported account, region and importer. Steps that run before importers
+// (loading aws config, getting account, resolving regions) have entries only if they fail.
+type ImportResult []ImportEntry
+
+// Status returns ok if all entries succeeded, failed if all entries failed, partial otherwise
+func (r ImportResult) Status() ImportStatus {
+	failed := r.Failed()
+	if failed == 0 {
+		return ImportOk
+	}
+	if failed == len(r) {
+		return ImportFailed
+	}
+	return ImportPartial
+}
+
+func (r ImportResult) Failed() int {
+	var failed int
+	for _, v := range r {
+		if v.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+type ImportEntry struct {
+	Profile   string
+	AccountId string
+	Region    string
+	Importer  string
+	Duration  time.Duration
+	Items     int
+	Pages     int
+	Retries   int
+	Err       error
+}
+
+func (e ImportEntry) Status() ImportStatus {
+	if e.Err != nil {
+		return ImportFailed
+	}
+	return ImportOk
+}
+
+func (e ImportEntry) MarshalJSON() ([]byte, error) {
+	var errMsg string
+	if e.Err != nil {
+		errMsg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Profile    string       `json:"profile"`
+		AccountId  string       `json:"account_id"`
+		Region     string       `json:"region"`
+		Importer   string       `json:"importer"`
+		Status     ImportStatus `json:"status"`
+		DurationMs int64        `json:"duration_ms"`
+		Items      int          `json:"items"`
+		Pages      int          `json:"pages"`
+		Retries    int          `json:"retries"`
+		Error      string       `json:"error,omitempty"`
+	}{
+		Profile:    e.Profile,
+		AccountId:  e.AccountId,
+		Region:     e.Region,
+		Importer:   e.Importer,
+		Status:     e.Status(),
+		DurationMs: e.Duration.Milliseconds(),
+		Items:      e.Items,
+		Pages:      e.Pages,
+		Retries:    e.Retries,
+		Error:      errMsg,
+	})
+}
+
+// importResults collects entries from concurrently running importers
+type importResults struct {
+	mu      sync.Mutex
+	entries ImportResult
+}
+
+func (r *importResults) add(entries ...ImportEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entries...)
+}
+
+// result returns entries sorted by profile, account, region and importer, so the result does not depend on the order
+// in which concurrent importers finished
+func (r *importResults) result() ImportResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := slices.Clone(r.entries)
+	slices.SortStableFunc(out, func(a, b ImportEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.Profile, b.Profile),
+			cmp.Compare(a.AccountId, b.AccountId),
+			cmp.Compare(a.Region, b.Region),
+			cmp.Compare(a.Importer, b.Importer),
+		)
+	})
+	return out
+}
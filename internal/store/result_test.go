@@ -0,0 +1,63 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestImportResult_Status(t *testing.T) {
+	tests := []struct {
+		result   ImportResult
+		expected ImportStatus
+	}{
+		{result: nil, expected: ImportOk},
+		{result: ImportResult{{}, {}}, expected: ImportOk},
+		{result: ImportResult{{}, {Err: errors.New("test")}}, expected: ImportPartial},
+		{result: ImportResult{{Err: errors.New("test")}}, expected: ImportFailed},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.result.Status())
+	}
+}
+
+func TestImportResults_result(t *testing.T) {
+	var results importResults
+	results.add(
+		ImportEntry{Profile: "prod", AccountId: "2", Region: "eu-west-2", Importer: ec2VpcsKey},
+		ImportEntry{Profile: "dev", AccountId: "1", Region: "eu-west-2", Importer: ec2VpcsKey},
+		ImportEntry{Profile: "prod", AccountId: "2", Region: "eu-west-1", Importer: ec2SubnetsKey},
+		ImportEntry{Profile: "prod", AccountId: "2", Region: "eu-west-1", Importer: ec2NetworkInterfacesKey},
+	)
+
+	var out []string
+	for _, v := range results.result() {
+		out = append(out, v.Profile+"/"+v.Region+"/"+v.Importer)
+	}
+	assert.Equal(t, []string{
+		"dev/eu-west-2/ec2.describe-vpcs",
+		"prod/eu-west-1/ec2.describe-network-interfaces",
+		"prod/eu-west-1/ec2.describe-subnets",
+		"prod/eu-west-2/ec2.describe-vpcs",
+	}, out)
+}
+
+func TestImportEntry_MarshalJSON(t *testing.T) {
+	entry := ImportEntry{
+		AccountId: "123456789012",
+		Region:    "eu-west-2",
+		Importer:  ec2VpcsKey,
+		Duration:  1500 * time.Millisecond,
+		Pages:     1,
+		Err:       errors.New("describe vpcs: throttled"),
+	}
+
+	b, err := json.Marshal(entry)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile": "", "account_id": "123456789012", "region": "eu-west-2", "importer": "ec2.describe-vpcs",
+"status": "failed", "duration_ms": 1500, "items": 0, "pages": 1, "retries": 0, "error": "describe vpcs: throttled"}`, string(b))
+}
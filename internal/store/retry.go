@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	"time"
+)
+
+const maxBackoff = 20 * time.Second
+
+// newRetryer returns adaptive retryer, that slows down requests when aws api is throttling and retries with
+// exponential jittered backoff. Retry quota is disabled, otherwise long running imports (many pages) run out of
+// retry tokens on throttling errors.
+func newRetryer(maxRetries int) func() aws.Retryer {
+	return func() aws.Retryer {
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, func(so *retry.StandardOptions) {
+				so.MaxAttempts = maxRetries + 1
+				so.Backoff = retry.NewExponentialJitterBackoff(maxBackoff)
+				so.RateLimiter = ratelimit.None
+			})
+		})
+	}
+}
+
+// callWithTimeout calls aws api with supplied timeout, the timeout applies to single call (page), including retries
+func callWithTimeout[T any](timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// importStats counts items, pages (api calls) and retries used by importer
+type importStats struct {
+	Items   int
+	Pages   int
+	Retries int
+}
+
+func (s *importStats) add(metadata middleware.Metadata, items int) {
+	s.Items += items
+	s.Pages++
+	if results, ok := retry.GetAttemptResults(metadata); ok && len(results.Results) > 1 {
+		s.Retries += len(results.Results) - 1
+	}
+}
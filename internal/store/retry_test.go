@@ -0,0 +1,49 @@
+package store
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDescribeVpcs_retryThrottling(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/xml")
+		switch calls {
+		case 1:
+			fmt.Fprint(w, `<DescribeVpcsResponse><vpcSet><item><vpcId>vpc-1</vpcId></item></vpcSet>
+<nextToken>page-2</nextToken></DescribeVpcsResponse>`)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `<Response><Errors><Error><Code>RequestLimitExceeded</Code><Message>Request limit exceeded.
+</Message></Error></Errors></Response>`)
+		default:
+			fmt.Fprint(w, `<DescribeVpcsResponse><vpcSet><item><vpcId>vpc-2</vpcId></item></vpcSet></DescribeVpcsResponse>`)
+		}
+	}))
+	defer server.Close()
+
+	svc := ec2.NewFromConfig(aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		Retryer:      newRetryer(2),
+	})
+
+	var stats importStats
+	vpcs, err := describeVpcs(svc, 10*time.Second, &stats)
+	require.NoError(t, err)
+	assert.Len(t, vpcs, 2)
+	assert.Equal(t, "vpc-2", aws.ToString(vpcs.([]ec2types.Vpc)[1].VpcId))
+	assert.Equal(t, importStats{Items: 2, Pages: 2, Retries: 1}, stats)
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pete911/awf/internal/types"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// SchemaVersion is version of stored resources. Version 1 is raw aws sdk structs (imports before awf had its own
+	// format), version 2 is awf types (types.Vpc, types.Subnet and types.NetworkInterface). Every snapshot records
+	// version it was written with, older versions are converted when read.
+	SchemaVersion    = 2
+	sdkSchemaVersion = 1
+	// minSchemaVersion is the oldest version that can be read
+	minSchemaVersion = sdkSchemaVersion
+
+	// schemaFile is schema version marker of the generation, generations without it are sdkSchemaVersion
+	schemaFile = "_schema"
+	// rawDir is directory of raw aws api responses in the generation, see ImportOptions.Raw
+	rawDir = "_raw"
+)
+
+// SchemaError is returned when stored data were written in schema version that this awf version cannot read
+type SchemaError struct {
+	Version int
+}
+
+func (e *SchemaError) Error() string {
+	if e.Version > SchemaVersion {
+		return fmt.Sprintf("stored data schema version %d is newer than supported version %d, upgrade awf", e.Version, SchemaVersion)
+	}
+	return fmt.Sprintf("stored data schema version %d is no longer supported, run 'awf import' again", e.Version)
+}
+
+func checkSchemaVersion(version int) error {
+	if version < minSchemaVersion || version > SchemaVersion {
+		return &SchemaError{Version: version}
+	}
+	return nil
+}
+
+type schema struct {
+	Version int `json:"version"`
+}
+
+// readSchemaVersion returns schema version of the generation, generations imported before the version marker was
+// introduced are sdkSchemaVersion
+func readSchemaVersion(enc encoder, generationDir string) (int, error) {
+	b, err := enc.readFile(filepath.Join(generationDir, schemaFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return sdkSchemaVersion, nil
+		}
+		return 0, err
+	}
+	var s schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return 0, fmt.Errorf("unmarshal %s: %w", filepath.Join(generationDir, schemaFile), err)
+	}
+	return s.Version, checkSchemaVersion(s.Version)
+}
+
+// normalize converts aws sdk resources returned by importers to stored types, account and region are not stored
+func normalize(v any) any {
+	switch in := v.(type) {
+	case []ec2types.Vpc:
+		return types.ToVpcs(types.Account{}, "", in)
+	case []ec2types.Subnet:
+		return types.ToSubnets(types.Account{}, "", in)
+	case []ec2types.NetworkInterface:
+		return types.ToNetworkInterfaces(types.Account{}, "", in)
+	}
+	return v
+}
+
+// codec decodes stored resource T, resources stored in sdkSchemaVersion are decoded as aws sdk struct S and converted
+type codec[T, S any] struct {
+	fromSdk func(account types.Account, region string, in S) T
+	locate  func(v *T, account types.Account, region string)
+}
+
+var (
+	vpcCodec = codec[types.Vpc, ec2types.Vpc]{
+		fromSdk: types.ToVpc,
+		locate:  func(v *types.Vpc, account types.Account, region string) { v.Account, v.Region = account, region },
+	}
+	subnetCodec = codec[types.Subnet, ec2types.Subnet]{
+		fromSdk: types.ToSubnet,
+		locate:  func(v *types.Subnet, account types.Account, region string) { v.Account, v.Region = account, region },
+	}
+	networkInterfaceCodec = codec[types.NetworkInterface, ec2types.NetworkInterface]{
+		fromSdk: types.ToNetworkInterface,
+		locate: func(v *types.NetworkInterface, account types.Account, region string) {
+			v.Account, v.Region = account, region
+		},
+	}
+)
+
+// decode decodes json array of resources stored in supplied schema version
+func (c codec[T, S]) decode(version int, b []byte, account types.Account, region string) ([]T, error) {
+	if err := checkSchemaVersion(version); err != nil {
+		return nil, err
+	}
+	if version == sdkSchemaVersion {
+		var in []S
+		if err := json.Unmarshal(b, &in); err != nil {
+			return nil, err
+		}
+		out := make([]T, 0, len(in))
+		for _, v := range in {
+			out = append(out, c.fromSdk(account, region, v))
+		}
+		return out, nil
+	}
+
+	var out []T
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		c.locate(&out[i], account, region)
+	}
+	return out, nil
+}
+
+// decodeOne decodes single json resource stored in supplied schema version
+func (c codec[T, S]) decodeOne(version int, b []byte, account types.Account, region string) (T, error) {
+	var out T
+	if err := checkSchemaVersion(version); err != nil {
+		return out, err
+	}
+	if version == sdkSchemaVersion {
+		var in S
+		if err := json.Unmarshal(b, &in); err != nil {
+			return out, err
+		}
+		return c.fromSdk(account, region, in), nil
+	}
+
+	if err := json.Unmarshal(b, &out); err != nil {
+		return out, err
+	}
+	c.locate(&out, account, region)
+	return out, nil
+}
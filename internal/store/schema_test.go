@@ -0,0 +1,41 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCodec_decode(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	in := []ec2types.NetworkInterface{{
+		NetworkInterfaceId: aws.String("eni-1"),
+		PrivateIpAddress:   aws.String("10.0.0.1"),
+		PrivateIpAddresses: []ec2types.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String("10.0.0.1")}},
+		Ipv6Addresses:      []ec2types.NetworkInterfaceIpv6Address{{Ipv6Address: aws.String("2001:db8::1")}},
+		Attachment:         &ec2types.NetworkInterfaceAttachment{AttachTime: aws.Time(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))},
+	}}
+	sdk, err := json.Marshal(in)
+	require.NoError(t, err)
+	normalized, err := json.Marshal(normalize(in))
+	require.NoError(t, err)
+
+	// resources stored in both versions are read the same, so snapshots can be compared
+	fromSdk, err := networkInterfaceCodec.decode(sdkSchemaVersion, sdk, account, "eu-west-2")
+	require.NoError(t, err)
+	fromNormalized, err := networkInterfaceCodec.decode(SchemaVersion, normalized, account, "eu-west-2")
+	require.NoError(t, err)
+	assert.Equal(t, fromSdk, fromNormalized)
+	assert.Equal(t, account, fromNormalized[0].Account)
+	assert.Equal(t, []string{"2001:db8::1"}, fromNormalized[0].Ipv6Addresses)
+	assert.NotContains(t, string(normalized), "Account")
+
+	_, err = networkInterfaceCodec.decode(SchemaVersion+1, normalized, account, "eu-west-2")
+	var schemaErr *SchemaError
+	assert.ErrorAs(t, err, &schemaErr)
+}
@@ -0,0 +1,1278 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pete911/awf/internal/index"
+	"github.com/pete911/awf/internal/types"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// SqliteBackend stores imported data in embedded (pure go) sqlite database in awf directory
+	SqliteBackend = "sqlite"
+	sqliteFile    = "awf.db"
+)
+
+// sqliteSchema has a table per resource, resources are stored as json (in snapshot schema version, see
+// SchemaVersion) with indexed ids, subnets and network interfaces have also indexed ids of their VPC and subnet. Network interface IPs (private and public) are in separate indexed table, CIDR and
+// IP prefix index (see index.Trie) of every snapshot is in prefix_indexes table, raw aws api responses (see
+// ImportOptions.Raw) are in raw_resources table. Every import of account region is a snapshot, resources of
+// uncommitted snapshot are not visible to readers.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS accounts (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS snapshots (
+	id          INTEGER PRIMARY KEY,
+	account_id  TEXT NOT NULL,
+	region      TEXT NOT NULL,
+	imported_at INTEGER NOT NULL,
+	committed   INTEGER NOT NULL DEFAULT 0,
+	resources   TEXT NOT NULL DEFAULT '[]',
+	schema_version INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snapshots_region_idx ON snapshots (account_id, region, committed, imported_at);
+CREATE TABLE IF NOT EXISTS vpcs (
+	snapshot_id INTEGER NOT NULL,
+	vpc_id      TEXT NOT NULL,
+	data        TEXT NOT NULL,
+	PRIMARY KEY (snapshot_id, vpc_id)
+);
+CREATE INDEX IF NOT EXISTS vpcs_id_idx ON vpcs (vpc_id);
+CREATE TABLE IF NOT EXISTS subnets (
+	snapshot_id INTEGER NOT NULL,
+	subnet_id   TEXT NOT NULL,
+	vpc_id      TEXT NOT NULL DEFAULT '',
+	data        TEXT NOT NULL,
+	PRIMARY KEY (snapshot_id, subnet_id)
+);
+CREATE INDEX IF NOT EXISTS subnets_id_idx ON subnets (subnet_id);
+CREATE INDEX IF NOT EXISTS subnets_vpc_idx ON subnets (snapshot_id, vpc_id);
+CREATE TABLE IF NOT EXISTS network_interfaces (
+	snapshot_id          INTEGER NOT NULL,
+	network_interface_id TEXT NOT NULL,
+	vpc_id               TEXT NOT NULL DEFAULT '',
+	subnet_id            TEXT NOT NULL DEFAULT '',
+	data                 TEXT NOT NULL,
+	PRIMARY KEY (snapshot_id, network_interface_id)
+);
+CREATE INDEX IF NOT EXISTS network_interfaces_id_idx ON network_interfaces (network_interface_id);
+CREATE INDEX IF NOT EXISTS network_interfaces_vpc_idx ON network_interfaces (snapshot_id, vpc_id);
+CREATE INDEX IF NOT EXISTS network_interfaces_subnet_idx ON network_interfaces (snapshot_id, subnet_id);
+CREATE TABLE IF NOT EXISTS network_interface_ips (
+	snapshot_id          INTEGER NOT NULL,
+	network_interface_id TEXT NOT NULL,
+	ip                   TEXT NOT NULL,
+	PRIMARY KEY (snapshot_id, network_interface_id, ip)
+);
+CREATE INDEX IF NOT EXISTS network_interface_ips_ip_idx ON network_interface_ips (ip);
+CREATE TABLE IF NOT EXISTS prefix_indexes (
+	snapshot_id INTEGER PRIMARY KEY,
+	data        BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS raw_resources (
+	snapshot_id INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	data        TEXT NOT NULL,
+	PRIMARY KEY (snapshot_id, name)
+);
+`
+
+// sqliteSchemaVersion is version of the database schema (user_version pragma), database created by newer awf version
+// is refused
+const sqliteSchemaVersion = 2
+
+// sqliteMigrations upgrade database schema from the version (key) to the next version, they run before sqliteSchema
+var sqliteMigrations = map[int]string{
+	// version 2 adds VPC and subnet ids of subnets and network interfaces
+	1: `
+ALTER TABLE subnets ADD COLUMN vpc_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE network_interfaces ADD COLUMN vpc_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE network_interfaces ADD COLUMN subnet_id TEXT NOT NULL DEFAULT '';
+UPDATE subnets SET vpc_id = COALESCE(json_extract(data, '$.vpc_id'), '');
+UPDATE network_interfaces SET vpc_id = COALESCE(json_extract(data, '$.vpc_id'), ''), subnet_id = COALESCE(json_extract(data, '$.subnet_id'), '');
+`,
+}
+
+// resourceTables are tables with resources of a snapshot, used when snapshot is removed
+var resourceTables = []string{"vpcs", "subnets", "network_interfaces", "network_interface_ips", "prefix_indexes", "raw_resources"}
+
+// SqliteOptions configures sqlite store. Path is the database file, default is awf.db in Dir (awf directory by
+// default). See FileOptions for Strict, ProfilePriority and Wait. Encryption (see Encoding) is not supported,
+// resource IDs and IPs are stored in indexed columns, so sqlite store cannot be loaded with encrypted encoding.
+type SqliteOptions struct {
+	Path            string
+	Dir             string
+	Strict          bool
+	Encoding        Encoding
+	ProfilePriority []string
+	Wait            bool
+}
+
+type Sqlite struct {
+	db              *sql.DB
+	path            string
+	strict          bool
+	profilePriority []string
+	wait            bool
+	at              time.Time
+	warnings        *warnings
+}
+
+var _ Store = Sqlite{}
+
+// LoadSqlite opens (and creates if it does not exist) sqlite database
+func LoadSqlite(opts SqliteOptions) (Sqlite, error) {
+	if opts.Encoding.Encrypted() {
+		return Sqlite{}, fmt.Errorf("sqlite store does not support encryption, unset %s and %s (key_file in config), or use file store", PassphraseEnv, KeyFileEnv)
+	}
+	path := opts.Path
+	if path == "" {
+		dir := opts.Dir
+		if dir == "" {
+			var err error
+			if dir, err = Dir(); err != nil {
+				return Sqlite{}, err
+			}
+		}
+		path = filepath.Join(dir, sqliteFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return Sqlite{}, err
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return Sqlite{}, fmt.Errorf("open %s: %w", path, err)
+	}
+	// sqlite has a single writer, concurrent imports are serialized by the connection
+	db.SetMaxOpenConns(1)
+	if err := createSqliteSchema(db); err != nil {
+		db.Close()
+		return Sqlite{}, fmt.Errorf("%s: %w", path, err)
+	}
+	// database is readable only by the owner, sqlite creates wal and shm files with the same permissions
+	if err := os.Chmod(path, 0600); err != nil {
+		db.Close()
+		return Sqlite{}, err
+	}
+	return Sqlite{db: db, path: path, strict: opts.Strict, profilePriority: opts.ProfilePriority, wait: opts.Wait, warnings: &warnings{}}, nil
+}
+
+// createSqliteSchema creates database schema if it does not exist, or upgrades older schema (see sqliteMigrations).
+// Database created by newer awf version is refused.
+func createSqliteSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d, upgrade awf", version, sqliteSchemaVersion)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// version 0 is new database
+	for v := version; v > 0 && v < sqliteSchemaVersion; v++ {
+		if _, err := tx.Exec(sqliteMigrations[v]); err != nil {
+			return fmt.Errorf("migrate schema version %d: %w", v, err)
+		}
+	}
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("create schema: %w", err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion)); err != nil {
+		return fmt.Errorf("write schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (s Sqlite) Close() error {
+	return s.db.Close()
+}
+
+func (s Sqlite) At(t time.Time) Store {
+	s.at = t
+	return s
+}
+
+func (s Sqlite) Warnings() []string {
+	return s.warnings.list()
+}
+
+func (s Sqlite) skip(err error) error {
+	if s.strict {
+		return err
+	}
+	s.warnings.add(err)
+	return nil
+}
+
+func (s Sqlite) ListAccounts() (types.Accounts, error) {
+	rows, err := s.db.Query("SELECT id, data FROM accounts ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts types.Accounts
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		var account types.Account
+		if err := json.Unmarshal([]byte(data), &account); err != nil {
+			if err := s.skip(fmt.Errorf("account %s: %w", id, err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		account.Profile = account.PreferredProfile(s.profilePriority)
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (s Sqlite) ListRegions(account types.Account) ([]string, error) {
+	rows, err := s.db.Query(
+		"SELECT DISTINCT region FROM snapshots WHERE account_id = ? AND committed = 1 ORDER BY region", account.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var regions []string
+	for rows.Next() {
+		var region string
+		if err := rows.Scan(&region); err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+	return regions, rows.Err()
+}
+
+func (s Sqlite) ListResources(account types.Account, region string) ([]Resource, error) {
+	snapshot, ok, err := s.snapshotAt(account.Id, region)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return snapshot.resources, nil
+}
+
+func (s Sqlite) ImportedAt(account types.Account, region string) (time.Time, error) {
+	resources, err := s.ListResources(account, region)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var importedAt time.Time
+	for _, v := range resources {
+		if importedAt.IsZero() || v.ImportedAt.Before(importedAt) {
+			importedAt = v.ImportedAt
+		}
+	}
+	return importedAt, nil
+}
+
+func (s Sqlite) ListSnapshots(account types.Account, region string) ([]time.Time, error) {
+	rows, err := s.db.Query(
+		"SELECT imported_at FROM snapshots WHERE account_id = ? AND region = ? AND committed = 1 ORDER BY imported_at",
+		account.Id, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []time.Time
+	for rows.Next() {
+		var importedAt int64
+		if err := rows.Scan(&importedAt); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, time.Unix(0, importedAt).UTC())
+	}
+	return snapshots, rows.Err()
+}
+
+func (s Sqlite) Snapshot(account types.Account, region string) (time.Time, bool) {
+	snapshot, ok, err := s.snapshotAt(account.Id, region)
+	if err != nil || !ok {
+		return time.Time{}, false
+	}
+	return snapshot.importedAt, true
+}
+
+func (s Sqlite) DescribeVpcs() (types.Vpcs, error) {
+	var vpcs types.Vpcs
+	err := s.forEachSnapshot(func(account types.Account, snapshot sqliteSnapshot) error {
+		snapshotVpcs, err := queryResources(s.db, vpcCodec, account, snapshot, "SELECT data FROM vpcs WHERE snapshot_id = ?", snapshot.id)
+		if err != nil {
+			return fmt.Errorf("account %s region %s: vpcs: %w", account.Id, snapshot.region, err)
+		}
+		vpcs = append(vpcs, snapshotVpcs...)
+		return nil
+	})
+	return vpcs, err
+}
+
+func (s Sqlite) DescribeSubnets() (types.Subnets, error) {
+	var subnets types.Subnets
+	err := s.forEachSnapshot(func(account types.Account, snapshot sqliteSnapshot) error {
+		snapshotSubnets, err := queryResources(s.db, subnetCodec, account, snapshot, "SELECT data FROM subnets WHERE snapshot_id = ?", snapshot.id)
+		if err != nil {
+			return fmt.Errorf("account %s region %s: subnets: %w", account.Id, snapshot.region, err)
+		}
+		subnets = append(subnets, snapshotSubnets...)
+		return nil
+	})
+	return subnets, err
+}
+
+func (s Sqlite) DescribeNetworkInterfaces() (types.NetworkInterfaces, error) {
+	var networkInterfaces types.NetworkInterfaces
+	err := s.forEachSnapshot(func(account types.Account, snapshot sqliteSnapshot) error {
+		nis, err := queryResources(s.db, networkInterfaceCodec, account, snapshot, "SELECT data FROM network_interfaces WHERE snapshot_id = ?", snapshot.id)
+		if err != nil {
+			return fmt.Errorf("account %s region %s: network interfaces: %w", account.Id, snapshot.region, err)
+		}
+		networkInterfaces = append(networkInterfaces, nis...)
+		return nil
+	})
+	return networkInterfaces, err
+}
+
+// GetNetworkInterfacesByIp returns network interfaces with the IP, using IP index
+func (s Sqlite) GetNetworkInterfacesByIp(ip string) (types.NetworkInterfaces, error) {
+	return querySnapshotResources(s, networkInterfaceCodec, "network interface", `
+		SELECT n.snapshot_id, n.data FROM network_interface_ips i
+		JOIN network_interfaces n ON n.snapshot_id = i.snapshot_id AND n.network_interface_id = i.network_interface_id
+		JOIN snapshots s ON s.id = i.snapshot_id
+		WHERE i.ip = ? AND `+currentSnapshot, ip, s.atNano())
+}
+
+// GetNetworkInterfacesById returns network interfaces with supplied IDs, using id index
+func (s Sqlite) GetNetworkInterfacesById(ids ...string) (types.NetworkInterfaces, error) {
+	return queryById(s, networkInterfaceCodec, "network interface", "network_interfaces", "network_interface_id", ids)
+}
+
+// GetVpcsById returns VPCs with supplied IDs, using id index
+func (s Sqlite) GetVpcsById(ids ...string) (types.Vpcs, error) {
+	return queryById(s, vpcCodec, "vpc", "vpcs", "vpc_id", ids)
+}
+
+// GetSubnetsById returns subnets with supplied IDs, using id index
+func (s Sqlite) GetSubnetsById(ids ...string) (types.Subnets, error) {
+	return queryById(s, subnetCodec, "subnet", "subnets", "subnet_id", ids)
+}
+
+// queryById returns resources of current snapshots with supplied IDs
+func queryById[T, S any](s Sqlite, c codec[T, S], name, table, idColumn string, ids []string) ([]T, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	return querySnapshotResources(s, c, name, fmt.Sprintf(`
+		SELECT r.snapshot_id, r.data FROM %s r
+		JOIN snapshots s ON s.id = r.snapshot_id
+		WHERE r.%s IN (%s) AND `, table, idColumn, placeholders)+currentSnapshot, append(args, s.atNano())...)
+}
+
+// Related returns VPCs of the subnets, subnets in the VPCs and network interfaces in the VPCs or subnets, using VPC
+// and subnet id indexes. Only snapshots of account regions of supplied resources are read.
+func (s Sqlite) Related(vpcs types.Vpcs, subnets types.Subnets) (LookupResult, error) {
+	var result LookupResult
+	for _, ids := range groupRelated(vpcs, subnets) {
+		snapshot, ok, err := s.snapshotAt(ids.account.Id, ids.region)
+		if err != nil {
+			return LookupResult{}, err
+		}
+		if !ok {
+			continue
+		}
+		regionResult, err := s.queryRelated(ids, snapshot)
+		if err != nil {
+			if err := s.skip(fmt.Errorf("account %s region %s: %w", ids.account.Id, ids.region, err)); err != nil {
+				return LookupResult{}, err
+			}
+			continue
+		}
+		result.add(regionResult)
+	}
+	return result, nil
+}
+
+func (s Sqlite) queryRelated(ids *relatedIds, snapshot sqliteSnapshot) (LookupResult, error) {
+	vpcId := func(v types.Vpc) string { return v.VpcId }
+	vpcs, err := queryResourcesById(s.db, vpcCodec, ids.account, snapshot, "vpcs", "vpc_id", ids.subnetVpcIds, vpcId)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	subnetVpcId := func(v types.Subnet) string { return v.VpcId }
+	subnets, err := queryResourcesById(s.db, subnetCodec, ids.account, snapshot, "subnets", "vpc_id", ids.vpcIds, subnetVpcId)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	niVpcId := func(v types.NetworkInterface) string { return v.VpcId }
+	nis, err := queryResourcesById(s.db, networkInterfaceCodec, ids.account, snapshot, "network_interfaces", "vpc_id", ids.vpcIds, niVpcId)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	niSubnetId := func(v types.NetworkInterface) string { return v.SubnetId }
+	subnetNis, err := queryResourcesById(s.db, networkInterfaceCodec, ids.account, snapshot, "network_interfaces", "subnet_id", ids.subnetIds, niSubnetId)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	// network interfaces in the subnets of the VPCs are already read
+	for _, v := range subnetNis {
+		if !ids.vpcIds[v.VpcId] {
+			nis = append(nis, v)
+		}
+	}
+	return ids.related(vpcs, subnets, nis), nil
+}
+
+// Lookup returns resources with CIDR or IP that overlaps the prefix, snapshot prefix index is used to find matched
+// resources and only matched rows are read
+func (s Sqlite) Lookup(prefix netip.Prefix) (LookupResult, error) {
+	var result LookupResult
+	err := s.forEachSnapshot(func(account types.Account, snapshot sqliteSnapshot) error {
+		ids, err := s.lookupIds(snapshot, prefix)
+		if err != nil {
+			return fmt.Errorf("account %s region %s: prefix index: %w", account.Id, snapshot.region, err)
+		}
+
+		vpcs, err := queryResourcesById(s.db, vpcCodec, account, snapshot, "vpcs", "vpc_id", ids[vpcIndexKey],
+			func(v types.Vpc) string { return v.VpcId })
+		if err != nil {
+			return fmt.Errorf("account %s region %s: vpcs: %w", account.Id, snapshot.region, err)
+		}
+		subnets, err := queryResourcesById(s.db, subnetCodec, account, snapshot, "subnets", "subnet_id", ids[subnetIndexKey],
+			func(v types.Subnet) string { return v.SubnetId })
+		if err != nil {
+			return fmt.Errorf("account %s region %s: subnets: %w", account.Id, snapshot.region, err)
+		}
+		nis, err := queryResourcesById(s.db, networkInterfaceCodec, account, snapshot, "network_interfaces", "network_interface_id", ids[networkInterfaceIndexKey],
+			func(v types.NetworkInterface) string { return v.NetworkInterfaceId })
+		if err != nil {
+			return fmt.Errorf("account %s region %s: network interfaces: %w", account.Id, snapshot.region, err)
+		}
+		result.add(LookupResult{Vpcs: vpcs, Subnets: subnets, NetworkInterfaces: nis})
+		return nil
+	})
+	return result, err
+}
+
+// lookupIds returns ids of the snapshot resources that overlap the prefix. Snapshots migrated before prefix indexes
+// were introduced do not have index, it is built from the snapshot resources.
+func (s Sqlite) lookupIds(snapshot sqliteSnapshot, prefix netip.Prefix) (map[string]map[string]bool, error) {
+	idx := index.New()
+	var data []byte
+	err := s.db.QueryRow("SELECT data FROM prefix_indexes WHERE snapshot_id = ?", snapshot.id).Scan(&data)
+	switch {
+	case err == nil:
+		if err := idx.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		if err := s.buildIndex(idx, snapshot); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return lookupIds(idx, prefix), nil
+}
+
+func (s Sqlite) buildIndex(idx *index.Trie, snapshot sqliteSnapshot) error {
+	vpcs, err := queryResources(s.db, vpcCodec, types.Account{}, snapshot, "SELECT data FROM vpcs WHERE snapshot_id = ?", snapshot.id)
+	if err != nil {
+		return err
+	}
+	subnets, err := queryResources(s.db, subnetCodec, types.Account{}, snapshot, "SELECT data FROM subnets WHERE snapshot_id = ?", snapshot.id)
+	if err != nil {
+		return err
+	}
+	nis, err := queryResources(s.db, networkInterfaceCodec, types.Account{}, snapshot, "SELECT data FROM network_interfaces WHERE snapshot_id = ?", snapshot.id)
+	if err != nil {
+		return err
+	}
+	indexResources(idx, types.Vpcs(vpcs))
+	indexResources(idx, types.Subnets(subnets))
+	indexResources(idx, types.NetworkInterfaces(nis))
+	return nil
+}
+
+func (s Sqlite) WalkNetworkInterfaces(fn func(account types.Account, region string, snapshot time.Time, nis types.NetworkInterfaces)) error {
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		rows, err := s.db.Query(
+			"SELECT id, region, imported_at, schema_version FROM snapshots WHERE account_id = ? AND committed = 1 ORDER BY region, imported_at",
+			account.Id)
+		if err != nil {
+			return err
+		}
+		var snapshots []sqliteSnapshot
+		for rows.Next() {
+			var snapshot sqliteSnapshot
+			var importedAt int64
+			if err := rows.Scan(&snapshot.id, &snapshot.region, &importedAt, &snapshot.schemaVersion); err != nil {
+				rows.Close()
+				return err
+			}
+			snapshot.importedAt = time.Unix(0, importedAt).UTC()
+			snapshots = append(snapshots, snapshot)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, snapshot := range snapshots {
+			nis, err := queryResources(s.db, networkInterfaceCodec, account, snapshot, "SELECT data FROM network_interfaces WHERE snapshot_id = ?", snapshot.id)
+			if err != nil {
+				if err := s.skip(fmt.Errorf("account %s region %s: network interfaces: %w", account.Id, snapshot.region, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			fn(account, snapshot.region, snapshot.importedAt, nis)
+		}
+	}
+	return nil
+}
+
+// WriteAccount writes account metadata, profiles and roles are merged with the stored account (see types.Account Merge)
+func (s Sqlite) WriteAccount(account types.Account) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data string
+	err = tx.QueryRow("SELECT data FROM accounts WHERE id = ?", account.Id).Scan(&data)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("read account data: %w", err)
+	}
+	var stored types.Account
+	if err == nil && json.Unmarshal([]byte(data), &stored) == nil {
+		account = stored.Merge(account)
+	}
+
+	b, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO accounts (id, data) VALUES (?, ?)", account.Id, string(b)); err != nil {
+		return fmt.Errorf("write account data: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s Sqlite) NewRegionWriter(account types.Account, region string, retention Retention) (RegionWriter, error) {
+	return s.newSnapshot(account.Id, region, retention)
+}
+
+// newSnapshot starts new (uncommitted) snapshot, concurrent imports of the same region (e.g. by another awf process)
+// wait for each other, see lockPath
+func (s Sqlite) newSnapshot(accountId, region string, retention Retention) (*sqliteRegionWriter, error) {
+	dir := filepath.Dir(s.path)
+	unlock, err := lock(lockPath(dir, accountId, region))
+	if err != nil {
+		return nil, err
+	}
+
+	// region is locked, so uncommitted snapshots of the region are from imports that did not finish (e.g. killed)
+	if err := s.removeUncommitted(accountId, region); err != nil {
+		return nil, errors.Join(err, unlock())
+	}
+	res, err := s.db.Exec(
+		"INSERT INTO snapshots (account_id, region, imported_at, schema_version) VALUES (?, ?, ?, ?)",
+		accountId, region, time.Now().UnixNano(), SchemaVersion)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("create snapshot: %w", err), unlock())
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("create snapshot: %w", err), unlock())
+	}
+	return &sqliteRegionWriter{
+		db:        s.db,
+		dir:       dir,
+		id:        id,
+		accountId: accountId,
+		region:    region,
+		retention: retention,
+		unlock:    unlock,
+		index:     index.New(),
+	}, nil
+}
+
+type sqliteSnapshot struct {
+	id            int64
+	accountId     string
+	region        string
+	importedAt    time.Time
+	resources     []Resource
+	schemaVersion int
+}
+
+// currentSnapshot is condition that selects committed snapshot (aliased s) that was current at the time (query
+// argument, see atNano)
+const currentSnapshot = `s.committed = 1 AND s.imported_at = (
+	SELECT MAX(imported_at) FROM snapshots
+	WHERE account_id = s.account_id AND region = s.region AND committed = 1 AND imported_at <= ?
+)`
+
+// atNano returns the time set by At, latest time if it is not set, see currentSnapshot
+func (s Sqlite) atNano() int64 {
+	if s.at.IsZero() {
+		return math.MaxInt64
+	}
+	return s.at.UnixNano()
+}
+
+// snapshots returns committed snapshots of every account region, that were current at the time set by At
+func (s Sqlite) snapshots(where string, args ...any) ([]sqliteSnapshot, error) {
+	rows, err := s.db.Query(`
+		SELECT id, account_id, region, imported_at, resources, schema_version FROM snapshots s
+		WHERE `+currentSnapshot+` `+where+`
+		ORDER BY account_id, region`, append([]any{s.atNano()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []sqliteSnapshot
+	for rows.Next() {
+		var snapshot sqliteSnapshot
+		var importedAt int64
+		var resources string
+		if err := rows.Scan(&snapshot.id, &snapshot.accountId, &snapshot.region, &importedAt, &resources, &snapshot.schemaVersion); err != nil {
+			return nil, err
+		}
+		snapshot.importedAt = time.Unix(0, importedAt).UTC()
+		if err := json.Unmarshal([]byte(resources), &snapshot.resources); err != nil {
+			return nil, fmt.Errorf("account %s region %s: unmarshal resources: %w", snapshot.accountId, snapshot.region, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+func (s Sqlite) snapshotAt(accountId, region string) (sqliteSnapshot, bool, error) {
+	snapshots, err := s.snapshots("AND account_id = ? AND region = ?", accountId, region)
+	if err != nil {
+		return sqliteSnapshot{}, false, s.skip(fmt.Errorf("account %s region %s: %w", accountId, region, err))
+	}
+	if len(snapshots) == 0 {
+		return sqliteSnapshot{}, false, nil
+	}
+	return snapshots[0], true, nil
+}
+
+// forEachSnapshot calls fn for every current snapshot (see At). Errors returned by fn are skipped (recorded as
+// warnings), unless the store is in strict mode.
+func (s Sqlite) forEachSnapshot(fn func(account types.Account, snapshot sqliteSnapshot) error) error {
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return err
+	}
+	snapshots, err := s.snapshots("")
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		account := accounts.GetById(snapshot.accountId)
+		if account.Id == "" {
+			if err := s.skip(fmt.Errorf("account %s: account data not found", snapshot.accountId)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(account, snapshot); err != nil {
+			if err := s.skip(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// querySnapshotResources returns resources from query that returns snapshot id and data of current snapshots (see
+// currentSnapshot)
+func querySnapshotResources[T, S any](s Sqlite, c codec[T, S], name, query string, args ...any) ([]T, error) {
+	var out []T
+	current := make(map[int64]sqliteSnapshot)
+	accounts := make(map[string]types.Account)
+	err := s.forEachSnapshot(func(account types.Account, snapshot sqliteSnapshot) error {
+		current[snapshot.id] = snapshot
+		accounts[account.Id] = account
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snapshotId int64
+		var data string
+		if err := rows.Scan(&snapshotId, &data); err != nil {
+			return nil, err
+		}
+		snapshot, ok := current[snapshotId]
+		if !ok {
+			continue
+		}
+		v, err := c.decodeOne(snapshot.schemaVersion, []byte(data), accounts[snapshot.accountId], snapshot.region)
+		if err != nil {
+			if err := s.skip(fmt.Errorf("account %s region %s: %s: %w", snapshot.accountId, snapshot.region, name, err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// queryResources returns resources of the snapshot from query that returns data column, resources are decoded
+// according to snapshot schema version
+func queryResources[T, S any](db *sql.DB, c codec[T, S], account types.Account, snapshot sqliteSnapshot, query string, args ...any) ([]T, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []T
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		v, err := c.decodeOne(snapshot.schemaVersion, []byte(data), account, snapshot.region)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal: %w", err)
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// maxQueryIds is the maximum number of ids queried by IN clause, more ids are filtered after reading all resources
+const maxQueryIds = 500
+
+// queryResourcesById returns resources of the snapshot with supplied ids
+func queryResourcesById[T, S any](db *sql.DB, c codec[T, S], account types.Account, snapshot sqliteSnapshot, table, idColumn string, ids map[string]bool, id func(T) string) ([]T, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > maxQueryIds {
+		all, err := queryResources(db, c, account, snapshot, fmt.Sprintf("SELECT data FROM %s WHERE snapshot_id = ?", table), snapshot.id)
+		if err != nil {
+			return nil, err
+		}
+		return filterById(all, ids, id), nil
+	}
+
+	args := []any{snapshot.id}
+	for id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	query := fmt.Sprintf("SELECT data FROM %s WHERE snapshot_id = ? AND %s IN (%s)", table, idColumn, placeholders)
+	return queryResources(db, c, account, snapshot, query, args...)
+}
+
+// sqliteRegionWriter writes resources to uncommitted snapshot, commit makes the snapshot visible to readers
+type sqliteRegionWriter struct {
+	db        *sql.DB
+	dir       string
+	id        int64
+	accountId string
+	region    string
+	retention Retention
+	// importedAt is set when existing snapshot is copied, otherwise commit time is used
+	importedAt time.Time
+	// unlock releases region lock, that is held until commit or discard
+	unlock func() error
+
+	mu        sync.Mutex
+	resources []Resource
+	index     *index.Trie
+}
+
+func (w *sqliteRegionWriter) Write(name string, v any, items int) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch in := v.(type) {
+	case types.Vpcs:
+		err = insertResources(tx, "INSERT OR REPLACE INTO vpcs (snapshot_id, vpc_id, data) VALUES (?, ?, ?)", w.id, in,
+			func(v types.Vpc) []any { return []any{v.VpcId} })
+	case types.Subnets:
+		err = insertResources(tx, "INSERT OR REPLACE INTO subnets (snapshot_id, subnet_id, vpc_id, data) VALUES (?, ?, ?, ?)", w.id, in,
+			func(v types.Subnet) []any { return []any{v.SubnetId, v.VpcId} })
+	case types.NetworkInterfaces:
+		err = insertResources(tx, "INSERT OR REPLACE INTO network_interfaces (snapshot_id, network_interface_id, vpc_id, subnet_id, data) VALUES (?, ?, ?, ?, ?)", w.id, in,
+			func(v types.NetworkInterface) []any { return []any{v.NetworkInterfaceId, v.VpcId, v.SubnetId} })
+		if err == nil {
+			err = insertNetworkInterfaceIps(tx, w.id, in)
+		}
+	default:
+		err = fmt.Errorf("unsupported resource %s type %T", name, v)
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resources = append(w.resources, Resource{Name: name, ImportedAt: time.Now().UTC(), Items: items})
+	indexResources(w.index, v)
+	return nil
+}
+
+// WriteRaw writes raw aws api response to raw_resources table
+func (w *sqliteRegionWriter) WriteRaw(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("write raw %s: %w", name, err)
+	}
+	if _, err := w.db.Exec("INSERT OR REPLACE INTO raw_resources (snapshot_id, name, data) VALUES (?, ?, ?)", w.id, name, string(b)); err != nil {
+		return fmt.Errorf("write raw %s: %w", name, err)
+	}
+	return nil
+}
+
+// Commit makes the snapshot current and removes snapshots that are out of retention, under exclusive store lock. If
+// commit fails, the snapshot is removed and current snapshot is kept.
+func (w *sqliteRegionWriter) Commit() error {
+	defer w.unlock()
+	if err := w.commit(); err != nil {
+		return errors.Join(err, w.remove())
+	}
+	return nil
+}
+
+func (w *sqliteRegionWriter) commit() error {
+	w.mu.Lock()
+	resources := slices.Clone(w.resources)
+	idx, err := w.index.MarshalBinary()
+	w.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("commit snapshot index: %w", err)
+	}
+	slices.SortFunc(resources, func(a, b Resource) int { return strings.Compare(a.Name, b.Name) })
+	importedAt := w.importedAt
+	if importedAt.IsZero() {
+		importedAt = time.Now().UTC()
+	}
+	b, err := json.Marshal(resources)
+	if err != nil {
+		return err
+	}
+
+	unlockStore, err := lock(lockPath(w.dir, storeLock))
+	if err != nil {
+		return err
+	}
+	defer unlockStore()
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE snapshots SET committed = 1, imported_at = ?, resources = ? WHERE id = ?",
+		importedAt.UnixNano(), string(b), w.id); err != nil {
+		return fmt.Errorf("commit snapshot: %w", err)
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO prefix_indexes (snapshot_id, data) VALUES (?, ?)", w.id, idx); err != nil {
+		return fmt.Errorf("commit snapshot index: %w", err)
+	}
+	if err := removeOldSnapshots(tx, w.accountId, w.region, w.id, w.retention); err != nil {
+		return fmt.Errorf("commit snapshot: %w", err)
+	}
+	return tx.Commit()
+}
+
+// keepImportTime sets import time of the snapshot and metadata of its resources, snapshot that is older than the
+// current one is added to the history
+func (w *sqliteRegionWriter) keepImportTime(importedAt time.Time, resources []Resource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.importedAt = importedAt
+	w.resources = slices.Clone(resources)
+}
+
+// Discard removes the snapshot, current snapshot is kept
+func (w *sqliteRegionWriter) Discard() error {
+	defer w.unlock()
+	return w.remove()
+}
+
+func (w *sqliteRegionWriter) remove() error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := removeSnapshot(tx, w.id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertResources inserts resources of the snapshot, query arguments are snapshot id, indexed columns of the resource
+// and json data
+func insertResources[T any](tx *sql.Tx, query string, snapshotId int64, in []T, columns func(T) []any) error {
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, v := range in {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		args := append([]any{snapshotId}, columns(v)...)
+		if _, err := stmt.Exec(append(args, string(b))...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertNetworkInterfaceIps(tx *sql.Tx, snapshotId int64, in types.NetworkInterfaces) error {
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO network_interface_ips (snapshot_id, network_interface_id, ip) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, v := range in {
+		for _, ip := range uniqueIps(v) {
+			if _, err := stmt.Exec(snapshotId, v.NetworkInterfaceId, ip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeOldSnapshots removes committed snapshots of the region that are out of retention, current snapshot is kept
+func removeOldSnapshots(tx *sql.Tx, accountId, region string, current int64, retention Retention) error {
+	rows, err := tx.Query(
+		"SELECT id, imported_at FROM snapshots WHERE account_id = ? AND region = ? AND committed = 1 ORDER BY imported_at",
+		accountId, region)
+	if err != nil {
+		return err
+	}
+	type snapshot struct {
+		id         int64
+		importedAt time.Time
+	}
+	var snapshots []snapshot
+	for rows.Next() {
+		var v snapshot
+		var importedAt int64
+		if err := rows.Scan(&v.id, &importedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		v.importedAt = time.Unix(0, importedAt)
+		snapshots = append(snapshots, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, v := range snapshots {
+		if v.id == current {
+			continue
+		}
+		newer := len(snapshots) - 1 - i
+		if (retention.Count > 0 && newer >= retention.Count) || (retention.MaxAge > 0 && time.Since(v.importedAt) > retention.MaxAge) {
+			if err := removeSnapshot(tx, v.id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func removeSnapshot(tx *sql.Tx, id int64) error {
+	for _, table := range resourceTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE snapshot_id = ?", id); err != nil {
+			return fmt.Errorf("remove snapshot from %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM snapshots WHERE id = ?", id); err != nil {
+		return fmt.Errorf("remove snapshot: %w", err)
+	}
+	return nil
+}
+
+// Migrate copies accounts and snapshots from file store to the database. Snapshots that are already in the database
+// (the same account, region and import time) are skipped, so migration can be re-run. Number of migrated snapshots is
+// returned.
+func (s Sqlite) Migrate(from File) (int, error) {
+	accounts, err := from.ListAccounts()
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated int
+	for _, account := range accounts {
+		if err := s.WriteAccount(account); err != nil {
+			return migrated, err
+		}
+		regions, err := from.ListRegions(account)
+		if err != nil {
+			if err := from.skip(fmt.Errorf("account %s: %w", account.Id, err)); err != nil {
+				return migrated, err
+			}
+			continue
+		}
+		for _, region := range regions {
+			n, err := s.migrateRegion(from, account, region)
+			migrated += n
+			if err != nil {
+				if err := from.skip(fmt.Errorf("account %s region %s: %w", account.Id, region, err)); err != nil {
+					return migrated, err
+				}
+			}
+		}
+	}
+	return migrated, nil
+}
+
+func (s Sqlite) migrateRegion(from File, account types.Account, region string) (int, error) {
+	regionDir := filepath.Join(from.dir, account.Id, region)
+	snapshots, err := listGenerations(regionDir)
+	if err != nil {
+		return 0, err
+	}
+
+	generations := make(map[time.Time]string)
+	for _, snapshot := range snapshots {
+		generations[snapshot] = filepath.Join(regionDir, snapshot.Format(generationFormat))
+	}
+	// region imported before snapshots were introduced, the current import is migrated
+	if len(snapshots) == 0 {
+		importedAt, err := from.ImportedAt(account, region)
+		if err != nil || importedAt.IsZero() {
+			return 0, err
+		}
+		snapshots = []time.Time{importedAt}
+		generations[importedAt] = currentGeneration(regionDir)
+	}
+
+	var migrated int
+	for _, snapshot := range snapshots {
+		var exists bool
+		err := s.db.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM snapshots WHERE account_id = ? AND region = ? AND imported_at = ? AND committed = 1)",
+			account.Id, region, snapshot.UnixNano()).Scan(&exists)
+		if err != nil {
+			return migrated, err
+		}
+		if exists {
+			continue
+		}
+		if err := s.migrateSnapshot(from, account, region, snapshot, generations[snapshot]); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+func (s Sqlite) migrateSnapshot(from File, account types.Account, region string, snapshot time.Time, generationDir string) error {
+	resources, err := readResources(from.enc, generationDir)
+	if err != nil {
+		return err
+	}
+	w, err := s.newSnapshot(account.Id, region, Retention{})
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range resources {
+		// resources are converted to the current schema version
+		var v any
+		switch resource.Name {
+		case ec2VpcsKey:
+			var vpcs []types.Vpc
+			vpcs, err = readGenerationResource(from, vpcCodec, generationDir, resource.Name, types.Account{}, "")
+			v = types.Vpcs(vpcs)
+		case ec2SubnetsKey:
+			var subnets []types.Subnet
+			subnets, err = readGenerationResource(from, subnetCodec, generationDir, resource.Name, types.Account{}, "")
+			v = types.Subnets(subnets)
+		case ec2NetworkInterfacesKey:
+			var nis []types.NetworkInterface
+			nis, err = readGenerationResource(from, networkInterfaceCodec, generationDir, resource.Name, types.Account{}, "")
+			v = types.NetworkInterfaces(nis)
+		default:
+			continue
+		}
+		if err == nil {
+			err = w.Write(resource.Name, v, resource.Items)
+		}
+		if err != nil {
+			return errors.Join(err, w.Discard())
+		}
+	}
+
+	// keep original import times of resources
+	w.keepImportTime(snapshot, resources)
+	return w.Commit()
+}
+
+// RemoveAccount removes the account and all its snapshots, see File RemoveAccount
+func (s Sqlite) RemoveAccount(account types.Account) error {
+	regions, err := s.ListRegions(account)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockRemoval(filepath.Dir(s.path), s.wait, account.Id, regions...)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.removeSnapshots(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM accounts WHERE id = ?", account.Id); err != nil {
+			return fmt.Errorf("remove account %s: %w", account.Id, err)
+		}
+		return nil
+	}, "SELECT id FROM snapshots WHERE account_id = ?", account.Id)
+}
+
+// RemoveRegion removes all snapshots of the account region
+func (s Sqlite) RemoveRegion(account types.Account, region string) error {
+	unlock, err := lockRemoval(filepath.Dir(s.path), s.wait, account.Id, region)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.removeSnapshots(nil, "SELECT id FROM snapshots WHERE account_id = ? AND region = ?", account.Id, region)
+}
+
+// RemoveSnapshots removes snapshots imported at supplied times, the latest committed (current) snapshot is kept
+func (s Sqlite) RemoveSnapshots(account types.Account, region string, snapshots []time.Time) error {
+	unlock, err := lockRemoval(filepath.Dir(s.path), s.wait, account.Id, region)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var errs []error
+	for _, t := range snapshots {
+		errs = append(errs, s.removeSnapshots(nil, `
+			SELECT id FROM snapshots WHERE account_id = ? AND region = ? AND committed = 1 AND imported_at = ?
+			AND imported_at < (SELECT MAX(imported_at) FROM snapshots WHERE account_id = ? AND region = ? AND committed = 1)`,
+			account.Id, region, t.UnixNano(), account.Id, region))
+	}
+	// region is locked, uncommitted snapshots are left by imports that did not finish
+	errs = append(errs, s.removeUncommitted(account.Id, region))
+	return errors.Join(errs...)
+}
+
+// removeUncommitted removes uncommitted snapshots of the region, region has to be locked
+func (s Sqlite) removeUncommitted(accountId, region string) error {
+	if err := s.removeSnapshots(nil, "SELECT id FROM snapshots WHERE account_id = ? AND region = ? AND committed = 0", accountId, region); err != nil {
+		return fmt.Errorf("remove unfinished import of account %s region %s: %w", accountId, region, err)
+	}
+	return nil
+}
+
+// removeSnapshots removes snapshots returned by query and calls fn (if not nil) in the same transaction
+func (s Sqlite) removeSnapshots(fn func(tx *sql.Tx) error, query string, args ...any) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := removeSnapshot(tx, id); err != nil {
+			return err
+		}
+	}
+	if fn != nil {
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
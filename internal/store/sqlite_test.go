@@ -0,0 +1,219 @@
+package store
+
+import (
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSqlite(t *testing.T) Sqlite {
+	s, err := LoadSqlite(SqliteOptions{Path: filepath.Join(t.TempDir(), sqliteFile), Strict: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func writeTestSqliteSnapshot(t *testing.T, s Sqlite, account types.Account, region string, retention Retention, vpcIds ...string) RegionWriter {
+	w, err := s.NewRegionWriter(account, region, retention)
+	require.NoError(t, err)
+
+	var vpcs types.Vpcs
+	var nis types.NetworkInterfaces
+	for _, id := range vpcIds {
+		vpcs = append(vpcs, types.Vpc{VpcId: id})
+		nis = append(nis, types.NetworkInterface{
+			NetworkInterfaceId: "eni-" + id,
+			VpcId:              id,
+			PrivateIpAddress:   "10.0.0.1",
+			PrivateIpAddresses: []string{"10.0.0.1"},
+		})
+	}
+	require.NoError(t, w.Write(ec2VpcsKey, vpcs, len(vpcs)))
+	require.NoError(t, w.Write(ec2SubnetsKey, types.Subnets{}, 0))
+	require.NoError(t, w.Write(ec2NetworkInterfacesKey, nis, len(nis)))
+	return w
+}
+
+func TestLoadSqlite_encryption(t *testing.T) {
+	_, err := LoadSqlite(SqliteOptions{Dir: t.TempDir(), Encoding: Encoding{Passphrase: "secret"}})
+	assert.ErrorContains(t, err, "does not support encryption")
+}
+
+func TestSqlite(t *testing.T) {
+	account := types.Account{Id: "123456789012", Profile: "test"}
+	s := newTestSqlite(t)
+	accounts, err := s.ListAccounts()
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	require.NoError(t, s.WriteAccount(account))
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-1").Commit())
+	// failed import is discarded, uncommitted snapshot is not visible
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-2").Discard())
+	firstSnapshot := time.Now()
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-3").Commit())
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-1", Retention{}, "vpc-4").Commit())
+
+	regions, err := s.ListRegions(account)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "eu-west-2"}, regions)
+
+	vpcs, err := s.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-4", "vpc-3"}, vpcIds(vpcs))
+	assert.Equal(t, account, vpcs[0].Account)
+
+	nis, err := s.GetNetworkInterfacesByIp("10.0.0.1")
+	require.NoError(t, err)
+	assert.Len(t, nis, 2)
+	nis, err = s.GetNetworkInterfacesById("eni-vpc-3")
+	require.NoError(t, err)
+	require.Len(t, nis, 1)
+	assert.Equal(t, "eu-west-2", nis[0].Region)
+	nis, err = s.GetNetworkInterfacesById("eni-vpc-3", "eni-vpc-4")
+	require.NoError(t, err)
+	assert.Len(t, nis, 2)
+
+	result, err := s.Lookup(netip.MustParsePrefix("10.0.0.0/24"))
+	require.NoError(t, err)
+	assert.Len(t, result.NetworkInterfaces, 2)
+	assert.Empty(t, result.Vpcs)
+
+	vpcs, err = s.At(firstSnapshot).DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+
+	resources, err := s.ListResources(account, "eu-west-2")
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+	assert.Equal(t, Resource{Name: ec2NetworkInterfacesKey, ImportedAt: resources[0].ImportedAt, Items: 1}, resources[0])
+
+	// retention removes snapshots over count
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{Count: 1}, "vpc-5").Commit())
+	snapshots, err := s.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+	nis, err = s.At(firstSnapshot).GetNetworkInterfacesByIp("10.0.0.1")
+	require.NoError(t, err)
+	assert.Empty(t, nis)
+
+	// account imported by another profile keeps both profiles
+	require.NoError(t, s.WriteAccount(types.Account{Id: account.Id, Profiles: []string{"admin"}}))
+	accounts, err = s.ListAccounts()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test", "admin"}, accounts[0].Profiles)
+	assert.Equal(t, "test", accounts[0].Profile)
+}
+
+func TestSqlite_uncommitted(t *testing.T) {
+	account := types.Account{Id: "123456789012", Profile: "test"}
+	s := newTestSqlite(t)
+	require.NoError(t, s.WriteAccount(account))
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-1").Commit())
+
+	// import that did not finish leaves uncommitted snapshot, it is removed by the next import of the region
+	w := writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-2").(*sqliteRegionWriter)
+	require.NoError(t, w.unlock())
+	require.NoError(t, writeTestSqliteSnapshot(t, s, account, "eu-west-2", Retention{}, "vpc-3").Commit())
+
+	var uncommitted int
+	require.NoError(t, s.db.QueryRow("SELECT COUNT(*) FROM snapshots WHERE committed = 0").Scan(&uncommitted))
+	assert.Zero(t, uncommitted)
+	var vpcs int
+	require.NoError(t, s.db.QueryRow("SELECT COUNT(*) FROM vpcs WHERE vpc_id = ?", "vpc-2").Scan(&vpcs))
+	assert.Zero(t, vpcs)
+}
+
+func TestSqlite_Migrate(t *testing.T) {
+	account := types.Account{Id: "123456789012", Profile: "test"}
+	f := newTestFile(t, account)
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", time.Now().Add(-48*time.Hour).UTC(), "vpc-1")
+	writeTestSnapshot(t, f, account.Id, "eu-west-2", time.Now().Add(-24*time.Hour).UTC(), "vpc-2")
+
+	s := newTestSqlite(t)
+	migrated, err := s.Migrate(f)
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	fileSnapshots, err := f.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	sqliteSnapshots, err := s.ListSnapshots(account, "eu-west-2")
+	require.NoError(t, err)
+	assert.Equal(t, fileSnapshots, sqliteSnapshots)
+	vpcs, err := s.DescribeVpcs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-2"}, vpcIds(vpcs))
+
+	// already migrated snapshots are skipped
+	migrated, err = s.Migrate(f)
+	require.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+}
+
+func TestSqlite_Related(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	s := newTestSqlite(t)
+	require.NoError(t, s.WriteAccount(account))
+	w, err := s.NewRegionWriter(account, "eu-west-2", Retention{})
+	require.NoError(t, err)
+	writeRelatedTestResources(t, w)
+	testRelated(t, s)
+}
+
+func TestLoadSqlite_migrateSchema(t *testing.T) {
+	account := types.Account{Id: "123456789012"}
+	path := filepath.Join(t.TempDir(), sqliteFile)
+	s, err := LoadSqlite(SqliteOptions{Path: path, Strict: true})
+	require.NoError(t, err)
+	require.NoError(t, s.WriteAccount(account))
+	w, err := s.NewRegionWriter(account, "eu-west-2", Retention{})
+	require.NoError(t, err)
+	writeRelatedTestResources(t, w)
+
+	// database in schema version 1 does not have vpc and subnet ids of subnets and network interfaces
+	_, err = s.db.Exec(`
+		DROP INDEX subnets_vpc_idx;
+		DROP INDEX network_interfaces_vpc_idx;
+		DROP INDEX network_interfaces_subnet_idx;
+		ALTER TABLE subnets DROP COLUMN vpc_id;
+		ALTER TABLE network_interfaces DROP COLUMN vpc_id;
+		ALTER TABLE network_interfaces DROP COLUMN subnet_id;
+		PRAGMA user_version = 1;`)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = LoadSqlite(SqliteOptions{Path: path, Strict: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	testRelated(t, s)
+}
+
+func BenchmarkSqlite_Lookup(b *testing.B) {
+	account := types.Account{Id: "123456789012"}
+	s, err := LoadSqlite(SqliteOptions{Path: filepath.Join(b.TempDir(), sqliteFile), Strict: true})
+	require.NoError(b, err)
+	b.Cleanup(func() { s.Close() })
+	require.NoError(b, s.WriteAccount(account))
+	w, err := s.NewRegionWriter(account, "eu-west-2", Retention{})
+	require.NoError(b, err)
+	writeBenchmarkResources(b, w)
+	benchmarkLookup(b, s)
+}
+
+func TestLoadSqlite_schemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sqliteFile)
+	s, err := LoadSqlite(SqliteOptions{Path: path, Strict: true})
+	require.NoError(t, err)
+
+	// database written by newer awf is refused
+	_, err = s.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion+1))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	_, err = LoadSqlite(SqliteOptions{Path: path, Strict: true})
+	assert.ErrorContains(t, err, "upgrade awf")
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"net/netip"
+	"time"
+)
+
+// FileBackend stores imported data as json files in awf directory, it is the default backend
+const FileBackend = "file"
+
+// Store is storage of imported data. Reads are tolerant (problems with stored data are skipped and recorded as
+// warnings), unless the store is loaded in strict mode.
+type Store interface {
+	// ListAccounts returns imported accounts. If the store does not exist, NotFoundError is returned.
+	ListAccounts() (types.Accounts, error)
+	ListRegions(account types.Account) ([]string, error)
+	// ListResources returns metadata (import time and number of items) of resources imported in the region
+	ListResources(account types.Account, region string) ([]Resource, error)
+	// ImportedAt returns import time of the oldest resource in the region, zero time if the region has no resources
+	ImportedAt(account types.Account, region string) (time.Time, error)
+	// ListSnapshots returns import times of stored snapshots of the region, oldest first
+	ListSnapshots(account types.Account, region string) ([]time.Time, error)
+	// Snapshot returns import time of the snapshot of the region that is read by the store, see At
+	Snapshot(account types.Account, region string) (time.Time, bool)
+	// At returns store that reads snapshots that were current at supplied time, zero time reads current import
+	At(t time.Time) Store
+
+	DescribeVpcs() (types.Vpcs, error)
+	DescribeSubnets() (types.Subnets, error)
+	DescribeNetworkInterfaces() (types.NetworkInterfaces, error)
+	// GetNetworkInterfacesByIp returns network interfaces with private or public IP, backend can use index instead of
+	// reading all network interfaces
+	GetNetworkInterfacesByIp(ip string) (types.NetworkInterfaces, error)
+	// GetNetworkInterfacesById returns network interfaces with supplied IDs
+	GetNetworkInterfacesById(ids ...string) (types.NetworkInterfaces, error)
+	// GetVpcsById returns VPCs with supplied IDs
+	GetVpcsById(ids ...string) (types.Vpcs, error)
+	// GetSubnetsById returns subnets with supplied IDs
+	GetSubnetsById(ids ...string) (types.Subnets, error)
+	// Related returns VPCs of the subnets, subnets in the VPCs and network interfaces in the VPCs or subnets, only
+	// account regions of supplied resources are read
+	Related(vpcs types.Vpcs, subnets types.Subnets) (LookupResult, error)
+	// Lookup returns VPCs, subnets and network interfaces with CIDR or IP that overlaps the prefix, using prefix index
+	Lookup(prefix netip.Prefix) (LookupResult, error)
+	// WalkNetworkInterfaces calls fn with network interfaces of every stored snapshot, oldest first per region
+	WalkNetworkInterfaces(fn func(account types.Account, region string, snapshot time.Time, nis types.NetworkInterfaces)) error
+
+	// Warnings returns problems with stored data that were skipped by reads
+	Warnings() []string
+
+	// WriteAccount writes account metadata, it is called before the account regions are imported
+	WriteAccount(account types.Account) error
+	// NewRegionWriter starts new import of the account region
+	NewRegionWriter(account types.Account, region string, retention Retention) (RegionWriter, error)
+
+	// RemoveAccount removes the account with all its imports. Account that is being imported is not removed,
+	// LockedError is returned instead (see Options Wait).
+	RemoveAccount(account types.Account) error
+	// RemoveRegion removes all imports (snapshots) of the account region, see RemoveAccount
+	RemoveRegion(account types.Account, region string) error
+	// RemoveSnapshots removes supplied snapshots of the account region, current snapshot is kept, see RemoveAccount
+	RemoveSnapshots(account types.Account, region string, snapshots []time.Time) error
+}
+
+// RegionWriter writes one import of account region. Written resources are visible to readers only after commit,
+// discarded import keeps the previous import.
+type RegionWriter interface {
+	// Write writes resource (slice of awf types, see SchemaVersion) with supplied number of items, it is safe to call
+	// Write concurrently
+	Write(name string, v any, items int) error
+	// WriteRaw writes raw aws api response of the resource, it is kept for troubleshooting and not read by awf
+	WriteRaw(name string, v any) error
+	Commit() error
+	Discard() error
+}
+
+// snapshotWriter is RegionWriter that can commit snapshot with its original import time instead of commit time, it is
+// used to copy snapshots between stores (e.g. migration or bundle load)
+type snapshotWriter interface {
+	RegionWriter
+	// keepImportTime sets import time of the snapshot and metadata of its resources, it is called before commit
+	keepImportTime(importedAt time.Time, resources []Resource)
+}
+
+// Options configures store. Backend is one of the store backends (default is FileBackend), Dir is awf directory
+// (default is Dir()) and Workspaces selects workspaces that are read (default workspace if empty), see
+// ResolveWorkspaces. See FileOptions for Strict, Encoding, ProfilePriority and Wait.
+type Options struct {
+	Backend         string
+	Dir             string
+	Workspaces      []string
+	Strict          bool
+	Encoding        Encoding
+	ProfilePriority []string
+	Wait            bool
+}
+
+// Load returns store backend set in options. If more than one workspace is selected, the workspaces are read together
+// (see Multi).
+func Load(opts Options) (Store, error) {
+	dir := opts.Dir
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			return nil, err
+		}
+	}
+	dirs, err := ResolveWorkspaces(dir, opts.Workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	var stores []Store
+	for _, workspaceDir := range dirs {
+		s, err := load(opts, workspaceDir)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, s)
+	}
+	if len(stores) == 1 {
+		return stores[0], nil
+	}
+	return NewMulti(stores...), nil
+}
+
+func load(opts Options, dir string) (Store, error) {
+	switch opts.Backend {
+	case "", FileBackend:
+		return LoadFile(FileOptions{
+			Dir:             dir,
+			Strict:          opts.Strict,
+			Encoding:        opts.Encoding,
+			ProfilePriority: opts.ProfilePriority,
+			Wait:            opts.Wait,
+		})
+	case SqliteBackend:
+		return LoadSqlite(SqliteOptions{
+			Dir:             dir,
+			Strict:          opts.Strict,
+			Encoding:        opts.Encoding,
+			ProfilePriority: opts.ProfilePriority,
+			Wait:            opts.Wait,
+		})
+	default:
+		return nil, fmt.Errorf("unknown store backend %s", opts.Backend)
+	}
+}
+
+// ItemCounts is number of stored VPCs, subnets and network interfaces, see CountItems
+type ItemCounts struct {
+	Vpcs              int
+	Subnets           int
+	NetworkInterfaces int
+}
+
+// CountItems counts items in snapshots read by the store (see At) from resource metadata, resources are not read.
+// Items of data imported before resource metadata were introduced are not counted.
+func CountItems(s Store) (ItemCounts, error) {
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return ItemCounts{}, err
+	}
+
+	var out ItemCounts
+	for _, account := range accounts {
+		regions, err := s.ListRegions(account)
+		if err != nil {
+			return ItemCounts{}, err
+		}
+		for _, region := range regions {
+			resources, err := s.ListResources(account, region)
+			if err != nil {
+				return ItemCounts{}, err
+			}
+			for _, v := range resources {
+				// number of items is unknown (negative) for data imported before resource metadata
+				if v.Items < 0 {
+					continue
+				}
+				switch v.Name {
+				case ec2VpcsKey:
+					out.Vpcs += v.Items
+				case ec2SubnetsKey:
+					out.Subnets += v.Items
+				case ec2NetworkInterfacesKey:
+					out.NetworkInterfaces += v.Items
+				}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"fmt"
+	"github.com/pete911/awf/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/netip"
+	"testing"
+)
+
+// writeRelatedTestResources writes two VPCs with a subnet each, vpc-1 has two network interfaces, vpc-2 has one
+func writeRelatedTestResources(t *testing.T, w RegionWriter) {
+	require.NoError(t, w.Write(ec2VpcsKey, types.Vpcs{{VpcId: "vpc-1"}, {VpcId: "vpc-2"}}, 2))
+	require.NoError(t, w.Write(ec2SubnetsKey, types.Subnets{
+		{SubnetId: "subnet-1", VpcId: "vpc-1"},
+		{SubnetId: "subnet-2", VpcId: "vpc-2"},
+	}, 2))
+	require.NoError(t, w.Write(ec2NetworkInterfacesKey, types.NetworkInterfaces{
+		{NetworkInterfaceId: "eni-1", VpcId: "vpc-1", SubnetId: "subnet-1"},
+		{NetworkInterfaceId: "eni-2", VpcId: "vpc-1", SubnetId: "subnet-1"},
+		{NetworkInterfaceId: "eni-3", VpcId: "vpc-2", SubnetId: "subnet-2"},
+	}, 3))
+	require.NoError(t, w.Commit())
+}
+
+func testRelated(t *testing.T, s Store) {
+	vpcs, err := s.GetVpcsById("vpc-1", "vpc-3")
+	require.NoError(t, err)
+	require.Equal(t, []string{"vpc-1"}, vpcIds(vpcs))
+	result, err := s.Related(vpcs, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Vpcs)
+	require.Len(t, result.Subnets, 1)
+	assert.Equal(t, "subnet-1", result.Subnets[0].SubnetId)
+	assert.ElementsMatch(t, []string{"eni-1", "eni-2"}, networkInterfaceIds(result.NetworkInterfaces))
+
+	subnets, err := s.GetSubnetsById("subnet-2")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	result, err = s.Related(vpcs, subnets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc-2"}, vpcIds(result.Vpcs))
+	assert.ElementsMatch(t, []string{"eni-1", "eni-2", "eni-3"}, networkInterfaceIds(result.NetworkInterfaces))
+
+	result, err = s.Related(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, LookupResult{}, result)
+}
+
+func networkInterfaceIds(nis types.NetworkInterfaces) []string {
+	var out []string
+	for _, v := range nis {
+		out = append(out, v.NetworkInterfaceId)
+	}
+	return out
+}
+
+const benchmarkSize = 1_000_000
+
+// writeBenchmarkResources writes region with 1M synthetic network interfaces (10.0.0.0/8)
+func writeBenchmarkResources(b *testing.B, w RegionWriter) {
+	nis := make(types.NetworkInterfaces, 0, benchmarkSize)
+	for i := range benchmarkSize {
+		ip := netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}).String()
+		nis = append(nis, types.NetworkInterface{
+			NetworkInterfaceId: fmt.Sprintf("eni-%08x", i),
+			VpcId:              "vpc-1",
+			SubnetId:           fmt.Sprintf("subnet-%04x", i>>8),
+			PrivateIpAddress:   ip,
+			PrivateIpAddresses: []string{ip},
+		})
+	}
+	require.NoError(b, w.Write(ec2VpcsKey, types.Vpcs{{VpcId: "vpc-1", CidrBlock: "10.0.0.0/8"}}, 1))
+	require.NoError(b, w.Write(ec2SubnetsKey, types.Subnets{}, 0))
+	require.NoError(b, w.Write(ec2NetworkInterfacesKey, nis, len(nis)))
+	require.NoError(b, w.Commit())
+}
+
+func benchmarkLookup(b *testing.B, s Store) {
+	prefix := netip.MustParsePrefix("10.8.1.2/32")
+	for b.Loop() {
+		result, err := s.Lookup(prefix)
+		require.NoError(b, err)
+		require.Len(b, result.NetworkInterfaces, 1)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(Options{Dir: dir})
+	require.NoError(t, err)
+	assert.IsType(t, File{}, s)
+
+	_, err = Load(Options{Backend: "unknown", Dir: dir})
+	assert.Error(t, err)
+
+	require.NoError(t, CreateWorkspace(dir, "prod"))
+	s, err = Load(Options{Dir: dir, Workspaces: []string{AllWorkspaces}})
+	require.NoError(t, err)
+	assert.IsType(t, Multi{}, s)
+
+	_, err = Load(Options{Dir: dir, Workspaces: []string{"dev"}})
+	assert.ErrorContains(t, err, "workspace dev does not exist")
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"slices"
+	"sync"
+)
+
+// warnings collects problems with stored data (e.g. missing or corrupted files) that were skipped when reading
+type warnings struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (w *warnings) add(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// the same file can be read multiple times (e.g. account file is read by every describe call)
+	if !slices.Contains(w.items, err.Error()) {
+		w.items = append(w.items, err.Error())
+	}
+}
+
+func (w *warnings) list() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.items)
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+const (
+	// DefaultWorkspace is the store in awf directory, named workspaces are in workspaces directory
+	DefaultWorkspace = "default"
+	// AllWorkspaces selects default and all named workspaces
+	AllWorkspaces = "all"
+
+	// HomeEnv is env. variable that overrides awf directory ($HOME/.awf)
+	HomeEnv = "AWF_HOME"
+
+	workspacesDir = "workspaces"
+)
+
+var workspaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// WorkspaceDir returns store directory of the workspace. Default workspace is the awf directory itself, so stores
+// created before workspaces are the default workspace.
+func WorkspaceDir(dir, name string) string {
+	if name == "" || name == DefaultWorkspace {
+		return dir
+	}
+	return filepath.Join(dir, workspacesDir, name)
+}
+
+// ListWorkspaces returns default workspace and named workspaces, sorted by name
+func ListWorkspaces(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, workspacesDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() && workspaceName.MatchString(e.Name()) {
+			out = append(out, e.Name())
+		}
+	}
+	slices.Sort(out)
+	return append([]string{DefaultWorkspace}, out...), nil
+}
+
+// ResolveWorkspaces returns store directories of supplied workspaces, 'all' selects every workspace. Named workspaces
+// have to be created first (see CreateWorkspace), so a typo does not create a new empty store.
+func ResolveWorkspaces(dir string, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{dir}, nil
+	}
+	if slices.Contains(names, AllWorkspaces) {
+		all, err := ListWorkspaces(dir)
+		if err != nil {
+			return nil, err
+		}
+		names = all
+	}
+
+	var out []string
+	for _, name := range names {
+		workspaceDir := WorkspaceDir(dir, name)
+		if name != DefaultWorkspace {
+			if _, err := os.Stat(workspaceDir); err != nil {
+				return nil, fmt.Errorf("workspace %s does not exist, run 'awf workspace create %s' first", name, name)
+			}
+		}
+		if !slices.Contains(out, workspaceDir) {
+			out = append(out, workspaceDir)
+		}
+	}
+	return out, nil
+}
+
+// CreateWorkspace creates named workspace
+func CreateWorkspace(dir, name string) error {
+	if err := validateWorkspaceName(name); err != nil {
+		return err
+	}
+	workspaceDir := WorkspaceDir(dir, name)
+	if _, err := os.Stat(workspaceDir); err == nil {
+		return fmt.Errorf("workspace %s already exists", name)
+	}
+	if err := os.MkdirAll(workspaceDir, 0700); err != nil {
+		return fmt.Errorf("create workspace %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteWorkspace removes named workspace and all data imported to it, default workspace cannot be deleted. Workspace
+// store lock is taken first, so the workspace is not deleted while it is read or import commits to it. LockedError is
+// returned if the workspace is locked, unless wait is set.
+func DeleteWorkspace(dir, name string, wait bool) error {
+	if err := validateWorkspaceName(name); err != nil {
+		return err
+	}
+	workspaceDir := WorkspaceDir(dir, name)
+	if _, err := os.Stat(workspaceDir); err != nil {
+		return fmt.Errorf("workspace %s does not exist", name)
+	}
+	unlock, err := tryLock(lockPath(workspaceDir, storeLock), wait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.RemoveAll(workspaceDir); err != nil {
+		return fmt.Errorf("delete workspace %s: %w", name, err)
+	}
+	return nil
+}
+
+func validateWorkspaceName(name string) error {
+	if name == DefaultWorkspace || name == AllWorkspaces {
+		return fmt.Errorf("workspace name %s is reserved", name)
+	}
+	if !workspaceName.MatchString(name) {
+		return fmt.Errorf("invalid workspace name %s, use lowercase letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaces(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, CreateWorkspace(dir, "prod"))
+	require.NoError(t, CreateWorkspace(dir, "dev"))
+	assert.Error(t, CreateWorkspace(dir, "dev"))
+	assert.Error(t, CreateWorkspace(dir, DefaultWorkspace))
+	assert.Error(t, CreateWorkspace(dir, AllWorkspaces))
+	assert.Error(t, CreateWorkspace(dir, "../dev"))
+
+	workspaces, err := ListWorkspaces(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{DefaultWorkspace, "dev", "prod"}, workspaces)
+
+	tests := []struct {
+		name     string
+		names    []string
+		expected []string
+	}{
+		{name: "default", expected: []string{dir}},
+		{name: "named", names: []string{"prod"}, expected: []string{filepath.Join(dir, "workspaces", "prod")}},
+		{name: "all", names: []string{AllWorkspaces, "prod"}, expected: []string{
+			dir,
+			filepath.Join(dir, "workspaces", "dev"),
+			filepath.Join(dir, "workspaces", "prod"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs, err := ResolveWorkspaces(dir, tt.names)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, dirs)
+		})
+	}
+
+	// workspace that is being read is not deleted
+	unlock, err := rlock(lockPath(WorkspaceDir(dir, "dev"), storeLock), false)
+	require.NoError(t, err)
+	var locked *LockedError
+	assert.ErrorAs(t, DeleteWorkspace(dir, "dev", false), &locked)
+	require.NoError(t, unlock())
+
+	require.NoError(t, DeleteWorkspace(dir, "dev", false))
+	_, err = ResolveWorkspaces(dir, []string{"dev"})
+	assert.Error(t, err)
+	assert.Error(t, DeleteWorkspace(dir, DefaultWorkspace, false))
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"path"
+	"slices"
+)
+
 type Accounts []Account
 
 func (a Accounts) GetById(in string) Account {
@@ -11,8 +16,68 @@ func (a Accounts) GetById(in string) Account {
 	return Account{}
 }
 
+// Account is imported aws account. Profiles and Roles are every aws profile and IAM role (SSO permission set for SSO
+// roles) that were used to import the account, in the order they were first seen. Profile is the preferred profile
+// (see PreferredProfile), Name is the account name in the organization.
 type Account struct {
-	Id      string `json:"id"`
-	Profile string `json:"profile"`
-	Alias   string `json:"alias"`
+	Id        string   `json:"id"`
+	Profile   string   `json:"profile"`
+	Profiles  []string `json:"profiles,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Alias     string   `json:"alias"`
+	Name      string   `json:"name,omitempty"`
+	Partition string   `json:"partition,omitempty"`
+}
+
+// AllProfiles returns all profiles of the account, accounts imported before profiles were recorded have only Profile
+func (a Account) AllProfiles() []string {
+	if len(a.Profiles) == 0 && a.Profile != "" {
+		return []string{a.Profile}
+	}
+	return a.Profiles
+}
+
+// Merge returns account with profiles and roles of both accounts, other (non-empty) fields of supplied account take
+// precedence. It is used to update stored account with a new import.
+func (a Account) Merge(in Account) Account {
+	out := a
+	out.Profiles = appendUnique(slices.Clone(a.AllProfiles()), in.AllProfiles()...)
+	out.Roles = appendUnique(slices.Clone(a.Roles), in.Roles...)
+	if in.Alias != "" {
+		out.Alias = in.Alias
+	}
+	if in.Name != "" {
+		out.Name = in.Name
+	}
+	if in.Partition != "" {
+		out.Partition = in.Partition
+	}
+	out.Profile = out.PreferredProfile(nil)
+	return out
+}
+
+// PreferredProfile returns the first profile that matches the first matching priority pattern (glob, e.g. '*-readonly'),
+// the first seen profile is returned if no profile matches
+func (a Account) PreferredProfile(priority []string) string {
+	profiles := a.AllProfiles()
+	for _, pattern := range priority {
+		for _, profile := range profiles {
+			if ok, _ := path.Match(pattern, profile); ok {
+				return profile
+			}
+		}
+	}
+	if len(profiles) == 0 {
+		return ""
+	}
+	return profiles[0]
+}
+
+func appendUnique(in []string, values ...string) []string {
+	for _, v := range values {
+		if v != "" && !slices.Contains(in, v) {
+			in = append(in, v)
+		}
+	}
+	return in
 }
@@ -4,33 +4,38 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"net/netip"
+	"slices"
 	"strings"
 	"time"
 )
 
 type NetworkInterfaces []NetworkInterface
 
+// NetworkInterface is stored by import (see store.SchemaVersion), account and region are set when the network
+// interface is read
 type NetworkInterface struct {
-	Account            Account
-	Region             string
-	VpcId              string
-	SubnetId           string
-	OwnerId            string
-	PublicIP           string
-	PublicDnsName      string
-	PrivateIpAddress   string
-	PrivateIpAddresses []string
-	PrivateDnsName     string
-	AttachTime         time.Time
-	AvailabilityZone   string
-	Description        string
-	InterfaceType      string
-	NetworkInterfaceId string
-	RequesterId        string
-	RequesterManaged   bool
-	InstanceId         string
-	Type               string
-	Status             string
+	Account            Account   `json:"-"`
+	Region             string    `json:"-"`
+	VpcId              string    `json:"vpc_id"`
+	SubnetId           string    `json:"subnet_id"`
+	OwnerId            string    `json:"owner_id"`
+	PublicIP           string    `json:"public_ip,omitempty"`
+	PublicDnsName      string    `json:"public_dns_name,omitempty"`
+	PrivateIpAddress   string    `json:"private_ip_address"`
+	PrivateIpAddresses []string  `json:"private_ip_addresses,omitempty"`
+	Ipv6Addresses      []string  `json:"ipv6_addresses,omitempty"`
+	PrivateDnsName     string    `json:"private_dns_name,omitempty"`
+	AttachTime         time.Time `json:"attach_time,omitzero"`
+	AvailabilityZone   string    `json:"availability_zone"`
+	Description        string    `json:"description,omitempty"`
+	InterfaceType      string    `json:"interface_type"`
+	NetworkInterfaceId string    `json:"network_interface_id"`
+	RequesterId        string    `json:"requester_id,omitempty"`
+	RequesterManaged   bool      `json:"requester_managed,omitempty"`
+	InstanceId         string    `json:"instance_id,omitempty"`
+	SecurityGroupIds   []string  `json:"security_group_ids,omitempty"`
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
 }
 
 func ToNetworkInterfaces(account Account, region string, in []types.NetworkInterface) NetworkInterfaces {
@@ -63,6 +68,16 @@ func ToNetworkInterface(account Account, region string, in types.NetworkInterfac
 		privateIpAddresses = append(privateIpAddresses, aws.ToString(address.PrivateIpAddress))
 	}
 
+	var ipv6Addresses []string
+	for _, address := range in.Ipv6Addresses {
+		ipv6Addresses = append(ipv6Addresses, aws.ToString(address.Ipv6Address))
+	}
+
+	var securityGroupIds []string
+	for _, group := range in.Groups {
+		securityGroupIds = append(securityGroupIds, aws.ToString(group.GroupId))
+	}
+
 	vpcId := aws.ToString(in.VpcId)
 	return NetworkInterface{
 		Account:            account,
@@ -74,6 +89,7 @@ func ToNetworkInterface(account Account, region string, in types.NetworkInterfac
 		PublicDnsName:      publicDnsName,
 		PrivateIpAddress:   aws.ToString(in.PrivateIpAddress),
 		PrivateIpAddresses: privateIpAddresses,
+		Ipv6Addresses:      ipv6Addresses,
 		PrivateDnsName:     aws.ToString(in.PrivateDnsName),
 		AttachTime:         attachTime,
 		AvailabilityZone:   aws.ToString(in.AvailabilityZone),
@@ -83,6 +99,7 @@ func ToNetworkInterface(account Account, region string, in types.NetworkInterfac
 		RequesterId:        aws.ToString(in.RequesterId),
 		RequesterManaged:   aws.ToBool(in.RequesterManaged),
 		InstanceId:         instanceId,
+		SecurityGroupIds:   securityGroupIds,
 		Type:               getNiType(in),
 		Status:             string(in.Status),
 	}
@@ -158,9 +175,14 @@ func (v NetworkInterfaces) GetByIp(ip string) NetworkInterfaces {
 	return out
 }
 
+// Ips returns private, public and IPv6 addresses of the network interface, addresses can repeat
+func (v NetworkInterface) Ips() []string {
+	return slices.Concat(v.PrivateIpAddresses, []string{v.PrivateIpAddress, v.PublicIP}, v.Ipv6Addresses)
+}
+
 func (v NetworkInterface) matchesIp(matcher func(in string) bool) bool {
 	// private ip address is already in private ip addresses slice, but just in case check all
-	for _, ip := range append(v.PrivateIpAddresses, v.PrivateIpAddress, v.PublicIP) {
+	for _, ip := range v.Ips() {
 		if matcher(ip) {
 			return true
 		}
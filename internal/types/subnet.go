@@ -79,16 +79,19 @@ func (v Subnets) GetByIp(in string) Subnets {
 	return out
 }
 
+// Subnet is stored by import (see store.SchemaVersion), account and region are set when the subnet is read
 type Subnet struct {
-	Account          Account
-	Region           string
-	SubnetId         string
-	Name             string
-	VpcId            string
-	CidrBlock        string
-	AvailabilityZone string
-	OwnerId          string
-	State            string
+	Account                 Account  `json:"-"`
+	Region                  string   `json:"-"`
+	SubnetId                string   `json:"subnet_id"`
+	Name                    string   `json:"name,omitempty"`
+	VpcId                   string   `json:"vpc_id"`
+	CidrBlock               string   `json:"cidr_block"`
+	Ipv6CidrBlocks          []string `json:"ipv6_cidr_blocks,omitempty"`
+	AvailabilityZone        string   `json:"availability_zone"`
+	AvailableIpAddressCount int      `json:"available_ip_address_count"`
+	OwnerId                 string   `json:"owner_id"`
+	State                   string   `json:"state"`
 }
 
 func ToSubnets(account Account, region string, in []types.Subnet) Subnets {
@@ -100,15 +103,22 @@ func ToSubnets(account Account, region string, in []types.Subnet) Subnets {
 }
 
 func ToSubnet(account Account, region string, in types.Subnet) Subnet {
+	var ipv6CidrBlocks []string
+	for _, v := range in.Ipv6CidrBlockAssociationSet {
+		ipv6CidrBlocks = append(ipv6CidrBlocks, aws.ToString(v.Ipv6CidrBlock))
+	}
+
 	return Subnet{
-		Account:          account,
-		Region:           region,
-		SubnetId:         aws.ToString(in.SubnetId),
-		Name:             toTags(in.Tags)["Name"],
-		VpcId:            aws.ToString(in.VpcId),
-		CidrBlock:        aws.ToString(in.CidrBlock),
-		AvailabilityZone: aws.ToString(in.AvailabilityZone),
-		OwnerId:          aws.ToString(in.OwnerId),
-		State:            string(in.State),
+		Account:                 account,
+		Region:                  region,
+		SubnetId:                aws.ToString(in.SubnetId),
+		Name:                    toTags(in.Tags)["Name"],
+		VpcId:                   aws.ToString(in.VpcId),
+		CidrBlock:               aws.ToString(in.CidrBlock),
+		Ipv6CidrBlocks:          ipv6CidrBlocks,
+		AvailabilityZone:        aws.ToString(in.AvailabilityZone),
+		AvailableIpAddressCount: int(aws.ToInt32(in.AvailableIpAddressCount)),
+		OwnerId:                 aws.ToString(in.OwnerId),
+		State:                   string(in.State),
 	}
 }
@@ -69,15 +69,17 @@ func (v Vpcs) GetByIp(in string) Vpcs {
 	return out
 }
 
+// Vpc is stored by import (see store.SchemaVersion), account and region are set when the VPC is read
 type Vpc struct {
-	Account   Account
-	Region    string
-	VpcId     string
-	Name      string
-	CidrBlock string
-	IsDefault bool
-	OwnerId   string
-	State     string
+	Account        Account  `json:"-"`
+	Region         string   `json:"-"`
+	VpcId          string   `json:"vpc_id"`
+	Name           string   `json:"name,omitempty"`
+	CidrBlock      string   `json:"cidr_block"`
+	Ipv6CidrBlocks []string `json:"ipv6_cidr_blocks,omitempty"`
+	IsDefault      bool     `json:"is_default,omitempty"`
+	OwnerId        string   `json:"owner_id"`
+	State          string   `json:"state"`
 }
 
 func ToVpcs(account Account, region string, in []types.Vpc) Vpcs {
@@ -89,14 +91,20 @@ func ToVpcs(account Account, region string, in []types.Vpc) Vpcs {
 }
 
 func ToVpc(account Account, region string, in types.Vpc) Vpc {
+	var ipv6CidrBlocks []string
+	for _, v := range in.Ipv6CidrBlockAssociationSet {
+		ipv6CidrBlocks = append(ipv6CidrBlocks, aws.ToString(v.Ipv6CidrBlock))
+	}
+
 	return Vpc{
-		Account:   account,
-		Region:    region,
-		VpcId:     aws.ToString(in.VpcId),
-		Name:      toTags(in.Tags)["Name"],
-		CidrBlock: aws.ToString(in.CidrBlock),
-		IsDefault: aws.ToBool(in.IsDefault),
-		OwnerId:   aws.ToString(in.OwnerId),
-		State:     string(in.State),
+		Account:        account,
+		Region:         region,
+		VpcId:          aws.ToString(in.VpcId),
+		Name:           toTags(in.Tags)["Name"],
+		CidrBlock:      aws.ToString(in.CidrBlock),
+		Ipv6CidrBlocks: ipv6CidrBlocks,
+		IsDefault:      aws.ToBool(in.IsDefault),
+		OwnerId:        aws.ToString(in.OwnerId),
+		State:          string(in.State),
 	}
 }